@@ -0,0 +1,126 @@
+package aggregate
+
+import "time"
+
+type Unit int
+
+const (
+	Hour Unit = iota
+	Day
+	Week
+	Month
+)
+
+func (u Unit) String() string {
+	switch u {
+	case Hour:
+		return "hour"
+	case Day:
+		return "day"
+	case Week:
+		return "week"
+	case Month:
+		return "month"
+	}
+	return "unknown"
+}
+
+// decides where buckets start. Hours follow the wall clock of Location, days start DayStart
+// after midnight, weeks on monday and months on the 1st, both at DayStart
+type Calendar struct {
+	Location *time.Location
+	DayStart time.Duration
+}
+
+// start of the given date. Built from the wall clock so the day start doesn't move across DST changes
+func (c Calendar) Date(y int, m time.Month, d int) time.Time {
+	hour, minute := int(c.DayStart/time.Hour), int(c.DayStart%time.Hour/time.Minute)
+	return time.Date(y, m, d, hour, minute, 0, 0, c.location())
+}
+
+// start of the bucket containing t
+func (c Calendar) Start(t time.Time, u Unit) time.Time {
+	t = t.In(c.location())
+	if u == Hour {
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+	}
+
+	day := c.Date(t.Date())
+	if t.Before(day) {
+		day = day.AddDate(0, 0, -1)
+	}
+	switch u {
+	case Week:
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case Month:
+		return c.Date(day.Year(), day.Month(), 1)
+	}
+	return day
+}
+
+// start of the bucket after the one starting at start
+func (c Calendar) Next(start time.Time, u Unit) time.Time {
+	switch u {
+	case Hour:
+		return start.Add(time.Hour)
+	case Week:
+		return start.AddDate(0, 0, 7)
+	case Month:
+		return start.AddDate(0, 1, 0)
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+func (c Calendar) location() *time.Location {
+	if c.Location == nil {
+		return time.Local
+	}
+	return c.Location
+}
+
+// tracked time of one thing (task, origin task, anything with an id) from Start to End
+type Interval struct {
+	Key   int64
+	Start time.Time
+	End   time.Time // zero while it's still running
+}
+
+// cuts iv into pieces that each lie in a single bucket, a running interval ends at now.
+// Empty and negative intervals give no pieces
+func (c Calendar) Split(iv Interval, u Unit, now time.Time) []Interval {
+	if iv.End.IsZero() {
+		iv.End = now
+	}
+	var pieces []Interval
+	for start := c.Start(iv.Start, u); start.Before(iv.End); start = c.Next(start, u) {
+		piece := Interval{Key: iv.Key, Start: start, End: c.Next(start, u)}
+		if piece.Start.Before(iv.Start) {
+			piece.Start = iv.Start
+		}
+		if piece.End.After(iv.End) {
+			piece.End = iv.End
+		}
+		if piece.End.After(piece.Start) {
+			pieces = append(pieces, piece)
+		}
+	}
+	return pieces
+}
+
+// time per bucket start and key
+type Totals map[time.Time]map[int64]time.Duration
+
+// sums the intervals into buckets of unit u, a running interval counts until now
+func (c Calendar) Sum(ivs []Interval, u Unit, now time.Time) Totals {
+	totals := make(Totals)
+	for _, iv := range ivs {
+		for _, piece := range c.Split(iv, u, now) {
+			bucket := c.Start(piece.Start, u)
+			if totals[bucket] == nil {
+				totals[bucket] = make(map[int64]time.Duration)
+			}
+			totals[bucket][piece.Key] += piece.End.Sub(piece.Start)
+		}
+	}
+	return totals
+}
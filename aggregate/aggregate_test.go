@@ -0,0 +1,261 @@
+package aggregate
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func berlin(t *testing.T) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+// wall clock time in loc, "2006-01-02 15:04"
+func at(t *testing.T, loc *time.Location, s string) time.Time {
+	t.Helper()
+	tm, err := time.ParseInLocation("2006-01-02 15:04", s, loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tm
+}
+
+func TestDate(t *testing.T) {
+	loc := berlin(t)
+	tests := []struct {
+		dayStart time.Duration
+		date     time.Time
+		want     string
+	}{
+		{0, time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC), "2025-03-10 00:00"},
+		{4*time.Hour + 30*time.Minute, time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC), "2025-03-10 04:30"},
+		// the clocks go from 2:00 to 3:00, the day still starts at 4:00 wall clock
+		{4 * time.Hour, time.Date(2025, 3, 30, 0, 0, 0, 0, time.UTC), "2025-03-30 04:00"},
+		{4 * time.Hour, time.Date(2025, 10, 26, 0, 0, 0, 0, time.UTC), "2025-10-26 04:00"},
+	}
+	for _, tt := range tests {
+		c := Calendar{Location: loc, DayStart: tt.dayStart}
+		got := c.Date(tt.date.Date())
+		if want := at(t, loc, tt.want); !got.Equal(want) || got.Location() != loc {
+			t.Errorf("Date(%s) with day start %v = %v, want %v", tt.date.Format("2006-01-02"), tt.dayStart, got, want)
+		}
+	}
+}
+
+func TestStart(t *testing.T) {
+	loc := berlin(t)
+	tests := []struct {
+		name     string
+		dayStart time.Duration
+		t        string
+		unit     Unit
+		want     string
+	}{
+		{"hour", 0, "2025-03-12 14:59", Hour, "2025-03-12 14:00"},
+		{"day", 0, "2025-03-12 14:59", Day, "2025-03-12 00:00"},
+		{"before day start", 4 * time.Hour, "2025-03-12 03:59", Day, "2025-03-11 04:00"},
+		{"at day start", 4 * time.Hour, "2025-03-12 04:00", Day, "2025-03-12 04:00"},
+		{"week", 0, "2025-03-16 23:00", Week, "2025-03-10 00:00"},
+		{"monday before day start", 4 * time.Hour, "2025-03-17 02:00", Week, "2025-03-10 04:00"},
+		{"month", 0, "2025-03-31 23:59", Month, "2025-03-01 00:00"},
+		{"first before day start", 4 * time.Hour, "2025-04-01 03:00", Month, "2025-03-01 04:00"},
+		{"day after spring forward", 0, "2025-03-30 12:00", Day, "2025-03-30 00:00"},
+		{"hour after spring forward", 0, "2025-03-30 03:30", Hour, "2025-03-30 03:00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Calendar{Location: loc, DayStart: tt.dayStart}
+			if got, want := c.Start(at(t, loc, tt.t), tt.unit), at(t, loc, tt.want); !got.Equal(want) {
+				t.Errorf("Start(%s, %v) = %v, want %v", tt.t, tt.unit, got, want)
+			}
+		})
+	}
+}
+
+func TestSplit(t *testing.T) {
+	loc := berlin(t)
+	type piece struct{ start, end string }
+	tests := []struct {
+		name       string
+		dayStart   time.Duration
+		start, end string // empty end for a running interval
+		now        string
+		unit       Unit
+		want       []piece
+	}{
+		{
+			name:  "inside one day",
+			start: "2025-03-12 09:00", end: "2025-03-12 17:00",
+			unit: Day,
+			want: []piece{{"2025-03-12 09:00", "2025-03-12 17:00"}},
+		},
+		{
+			name:  "several days",
+			start: "2025-03-10 22:00", end: "2025-03-13 01:00",
+			unit: Day,
+			want: []piece{
+				{"2025-03-10 22:00", "2025-03-11 00:00"},
+				{"2025-03-11 00:00", "2025-03-12 00:00"},
+				{"2025-03-12 00:00", "2025-03-13 00:00"},
+				{"2025-03-13 00:00", "2025-03-13 01:00"},
+			},
+		},
+		{
+			name:  "running",
+			start: "2025-03-12 23:00",
+			now:   "2025-03-13 01:30",
+			unit:  Day,
+			want: []piece{
+				{"2025-03-12 23:00", "2025-03-13 00:00"},
+				{"2025-03-13 00:00", "2025-03-13 01:30"},
+			},
+		},
+		{
+			name:  "empty",
+			start: "2025-03-12 09:00", end: "2025-03-12 09:00",
+			unit: Day,
+		},
+		{
+			name:  "negative",
+			start: "2025-03-12 09:00", end: "2025-03-12 08:00",
+			unit: Day,
+		},
+		{
+			name:     "night before day start",
+			dayStart: 4 * time.Hour,
+			start:    "2025-03-12 22:00", end: "2025-03-13 05:00",
+			unit: Day,
+			want: []piece{
+				{"2025-03-12 22:00", "2025-03-13 04:00"},
+				{"2025-03-13 04:00", "2025-03-13 05:00"},
+			},
+		},
+		{
+			name:  "spring forward day",
+			start: "2025-03-29 12:00", end: "2025-03-31 12:00",
+			unit: Day,
+			want: []piece{
+				{"2025-03-29 12:00", "2025-03-30 00:00"},
+				{"2025-03-30 00:00", "2025-03-31 00:00"},
+				{"2025-03-31 00:00", "2025-03-31 12:00"},
+			},
+		},
+		{
+			name:  "spring forward hours",
+			start: "2025-03-30 01:30", end: "2025-03-30 03:30",
+			unit: Hour,
+			want: []piece{
+				{"2025-03-30 01:30", "2025-03-30 03:00"},
+				{"2025-03-30 03:00", "2025-03-30 03:30"},
+			},
+		},
+		{
+			name:     "fall back with day start",
+			dayStart: 4 * time.Hour,
+			start:    "2025-10-25 12:00", end: "2025-10-27 12:00",
+			unit: Day,
+			want: []piece{
+				{"2025-10-25 12:00", "2025-10-26 04:00"},
+				{"2025-10-26 04:00", "2025-10-27 04:00"},
+				{"2025-10-27 04:00", "2025-10-27 12:00"},
+			},
+		},
+		{
+			name:  "over a week boundary",
+			start: "2025-03-16 22:00", end: "2025-03-17 02:00",
+			unit: Week,
+			want: []piece{
+				{"2025-03-16 22:00", "2025-03-17 00:00"},
+				{"2025-03-17 00:00", "2025-03-17 02:00"},
+			},
+		},
+		{
+			name:     "week with day start",
+			dayStart: 4 * time.Hour,
+			start:    "2025-03-16 22:00", end: "2025-03-17 02:00",
+			unit: Week,
+			want: []piece{{"2025-03-16 22:00", "2025-03-17 02:00"}},
+		},
+		{
+			name:  "over a month boundary",
+			start: "2025-01-31 20:00", end: "2025-03-01 04:00",
+			unit: Month,
+			want: []piece{
+				{"2025-01-31 20:00", "2025-02-01 00:00"},
+				{"2025-02-01 00:00", "2025-03-01 00:00"},
+				{"2025-03-01 00:00", "2025-03-01 04:00"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Calendar{Location: loc, DayStart: tt.dayStart}
+			iv := Interval{Key: 7, Start: at(t, loc, tt.start)}
+			if tt.end != "" {
+				iv.End = at(t, loc, tt.end)
+			}
+			var now time.Time
+			if tt.now != "" {
+				now = at(t, loc, tt.now)
+			}
+			got := c.Split(iv, tt.unit, now)
+			if len(got) != len(tt.want) {
+				t.Fatalf("%d pieces, want %d: %v", len(got), len(tt.want), got)
+			}
+			for i, w := range tt.want {
+				if got[i].Key != 7 || !got[i].Start.Equal(at(t, loc, w.start)) || !got[i].End.Equal(at(t, loc, w.end)) {
+					t.Errorf("piece %d = %v - %v, want %s - %s", i, got[i].Start, got[i].End, w.start, w.end)
+				}
+			}
+		})
+	}
+}
+
+func TestSum(t *testing.T) {
+	loc := berlin(t)
+	c := Calendar{Location: loc, DayStart: 4 * time.Hour}
+	ivs := []Interval{
+		{Key: 1, Start: at(t, loc, "2025-03-29 20:00"), End: at(t, loc, "2025-03-30 06:00")},
+		{Key: 1, Start: at(t, loc, "2025-03-30 10:00"), End: at(t, loc, "2025-03-30 11:00")},
+		{Key: 2, Start: at(t, loc, "2025-03-30 12:00")},
+	}
+	now := at(t, loc, "2025-03-31 05:00")
+
+	days := c.Sum(ivs, Day, now)
+	want := map[string]map[int64]time.Duration{
+		// 8 wall clock hours to 4:00, one of them skipped by the clock change
+		"2025-03-29 04:00": {1: 7 * time.Hour},
+		"2025-03-30 04:00": {1: 3 * time.Hour, 2: 16 * time.Hour},
+		"2025-03-31 04:00": {2: time.Hour},
+	}
+	if len(days) != len(want) {
+		t.Fatalf("%d days, want %d: %v", len(days), len(want), days)
+	}
+	for day, keys := range want {
+		got := days[at(t, loc, day)]
+		for k, d := range keys {
+			if got[k] != d {
+				t.Errorf("%s key %d = %v, want %v", day, k, got[k], d)
+			}
+		}
+		if len(got) != len(keys) {
+			t.Errorf("%s has keys %v, want %v", day, got, keys)
+		}
+	}
+
+	weeks := c.Sum(ivs, Week, now)
+	week := weeks[at(t, loc, "2025-03-24 04:00")]
+	if len(weeks) != 2 || week[1] != 10*time.Hour || week[2] != 16*time.Hour {
+		t.Errorf("weeks = %v", weeks)
+	}
+	months := c.Sum(ivs, Month, now)
+	month := months[at(t, loc, "2025-03-01 04:00")]
+	if len(months) != 1 || month[1] != 10*time.Hour || month[2] != 17*time.Hour {
+		t.Errorf("months = %v", months)
+	}
+}
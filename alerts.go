@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// how long the window title flashes after a milestone
+const titleFlashFor = 10 * time.Second
+
+// in-terminal alerts for when the TUI sits in a background tmux pane, see the alert_* config
+type terminalAlerts struct {
+	bell        bool
+	flashTitle  bool
+	windowTitle bool
+	// current title state, so the title is only sent when it changes
+	title      string
+	flash      string
+	flashUntil time.Time
+}
+
+// rings the bell and starts flashing the title for the milestones just reached
+func (m model) alertTerminal(reached []milestone, now time.Time) (model, tea.Cmd) {
+	if len(reached) == 0 {
+		return m, nil
+	}
+	if m.alerts.flashTitle {
+		m.alerts.flash = reached[len(reached)-1].title
+		m.alerts.flashUntil = now.Add(titleFlashFor)
+	}
+	if !m.alerts.bell {
+		return m, nil
+	}
+	// the renderer owns the terminal, the BEL goes out with a line printed above the TUI so it
+	// doesn't land in the middle of a frame. The line keeps the milestones in the scrollback
+	titles := make([]string, len(reached))
+	for i, ms := range reached {
+		titles[i] = ms.title
+	}
+	return m, tea.Printf("\a★ %s %s", now.Format("15:04"), strings.Join(titles, ", "))
+}
+
+// the running task and elapsed time, alternating with the last milestone while it flashes
+func (m model) windowTitle(now time.Time) string {
+	if !m.alerts.windowTitle && !m.alerts.flashTitle {
+		return ""
+	}
+	if now.Before(m.alerts.flashUntil) && now.Unix()%2 == 0 {
+		return "★ " + m.alerts.flash
+	}
+	if !m.alerts.windowTitle {
+		return "negentropy"
+	}
+	if m.Timer.Running {
+		return fmt.Sprintf("▶ %s %s · negentropy", m.tasks[m.ActiveTaskId].Name, formatDuration(m.Timer.SessionTime))
+	}
+	return "negentropy"
+}
+
+// sends the window title if it changed, called whenever the stopwatch ticks, starts or stops
+func (m model) updateTitle(now time.Time) (model, tea.Cmd) {
+	title := m.windowTitle(now)
+	if title == "" || title == m.alerts.title {
+		return m, nil
+	}
+	m.alerts.title = title
+	return m, tea.SetWindowTitle(title)
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAlertBell(t *testing.T) {
+	now := time.Date(2025, 3, 12, 14, 25, 0, 0, time.Local)
+	reached := []milestone{{title: "Pomodoro done"}, {title: "Daily target reached"}}
+
+	m := model{alerts: terminalAlerts{bell: true, flashTitle: true}}
+	m, cmd := m.alertTerminal(reached, now)
+	if cmd == nil {
+		t.Fatal("no bell")
+	}
+	// printed through the program, which writes it between frames
+	if got := fmt.Sprint(cmd()); !strings.Contains(got, "\a★ 14:25 Pomodoro done, Daily target reached") {
+		t.Errorf("printed %q", got)
+	}
+	if m.alerts.flash != "Daily target reached" || !m.alerts.flashUntil.Equal(now.Add(titleFlashFor)) {
+		t.Errorf("flash = %q until %v", m.alerts.flash, m.alerts.flashUntil)
+	}
+
+	m = model{alerts: terminalAlerts{flashTitle: true}}
+	if _, cmd := m.alertTerminal(reached, now); cmd != nil {
+		t.Error("rang with alert_bell off")
+	}
+	m = model{alerts: terminalAlerts{bell: true}}
+	if _, cmd := m.alertTerminal(nil, now); cmd != nil {
+		t.Error("rang without a milestone")
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// daily backups are named daily-YYYY-MM-DD.sqlite inside the backup dir
+const dailyBackupPrefix = "daily-"
+
+// copies the whole database with SQLite's online backup API, so the copy is consistent
+// even while the TUI is writing to the source
+func copyDB(ctx context.Context, from, to *sql.DB) error {
+	src, err := from.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := to.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	return dst.Raw(func(dstConn any) error {
+		return src.Raw(func(srcConn any) error {
+			d, ok := dstConn.(*sqlite3.SQLiteConn)
+			s, ok2 := srcConn.(*sqlite3.SQLiteConn)
+			if !ok || !ok2 {
+				return fmt.Errorf("backup needs sqlite3 connections")
+			}
+			b, err := d.Backup("main", s, "main")
+			if err != nil {
+				return err
+			}
+			// -1 copies everything in one step while holding a read lock on the source
+			if _, err := b.Step(-1); err != nil {
+				b.Finish()
+				return err
+			}
+			return b.Finish()
+		})
+	})
+}
+
+// writes a snapshot of db to path, through a temp file so a half written backup never
+// has the final name
+func backupDB(ctx context.Context, db *sql.DB, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	os.Remove(tmp)
+	dst, err := sql.Open("sqlite3", tmp)
+	if err != nil {
+		return err
+	}
+	if err := copyDB(ctx, db, dst); err != nil {
+		dst.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// checks that path is a readable negentropy database at the same migration as db before
+// anything gets overwritten with it. A backup from before a later migration would leave the
+// app without the columns it expects
+func validateBackup(ctx context.Context, db *sql.DB, path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+	src, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	var tables int
+	err = src.QueryRowContext(ctx, "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name IN ('tasks', 'sessions')").Scan(&tables)
+	if err != nil {
+		return fmt.Errorf("%s is not a negentropy database: %w", path, err)
+	}
+	if tables != 2 {
+		return fmt.Errorf("%s is not a negentropy database", path)
+	}
+	have, err := schemaVersion(ctx, src)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	want, err := schemaVersion(ctx, db)
+	if err != nil {
+		return err
+	}
+	if have < want {
+		return fmt.Errorf("%s is at migration %d and the database at %d, migrate a copy of it first:\n\n    goose -dir database/schema sqlite3 <copy> up", path, have, want)
+	}
+	if have > want {
+		return fmt.Errorf("%s is at migration %d, newer than this database (%d), migrate the database first", path, have, want)
+	}
+	return nil
+}
+
+// the last migration goose applied, a rolled back one doesn't count
+func schemaVersion(ctx context.Context, db *sql.DB) (int64, error) {
+	var v int64
+	err := db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version_id), 0)
+		FROM goose_db_version g
+		WHERE is_applied
+		AND id = (SELECT MAX(id) FROM goose_db_version WHERE version_id = g.version_id)`).Scan(&v)
+	if err != nil {
+		return 0, fmt.Errorf("reading the migration version: %w", err)
+	}
+	return v, nil
+}
+
+// replaces the contents of db with the backup at path, after saving them to safety. Nothing
+// is saved or replaced when the backup doesn't fit this database
+func restoreDB(ctx context.Context, db *sql.DB, path, safety string) error {
+	if err := validateBackup(ctx, db, path); err != nil {
+		return err
+	}
+	if err := backupDB(ctx, db, safety); err != nil {
+		return fmt.Errorf("couldn't back up the current database, not restoring: %w", err)
+	}
+	src, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	return copyDB(ctx, src, db)
+}
+
+// makes today's backup if there isn't one yet and removes daily backups beyond the newest keep.
+// Returns the path of the new backup, empty if today's already existed
+func rotateDailyBackup(ctx context.Context, db *sql.DB, dir string, keep int, now time.Time) (string, error) {
+	if keep <= 0 {
+		return "", nil
+	}
+	// named after the day it belongs to with the day_start and timezone config, not the date
+	path := filepath.Join(dir, dailyBackupPrefix+startOfDay(now).Format(dateLayout)+".sqlite")
+	created := ""
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if err := backupDB(ctx, db, path); err != nil {
+			return "", err
+		}
+		created = path
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return created, err
+	}
+	var daily []string
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), dailyBackupPrefix) && strings.HasSuffix(e.Name(), ".sqlite") {
+			daily = append(daily, e.Name())
+		}
+	}
+	// the date in the name sorts chronologically
+	sort.Sort(sort.Reverse(sort.StringSlice(daily)))
+	for _, name := range daily[min(keep, len(daily)):] {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			return created, err
+		}
+	}
+	return created, nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	db "github.com/chee-zer/negentropy/database/sqlc"
+)
+
+func TestRestoreChecksMigration(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	current := openTestDBAt(t, filepath.Join(dir, "current.sqlite"), -1)
+	latest := openTestDBAt(t, filepath.Join(dir, "latest.sqlite"), -1)
+	if _, err := db.New(latest).CreateTask(ctx, db.CreateTaskParams{Name: "from backup"}); err != nil {
+		t.Fatal(err)
+	}
+	openTestDBAt(t, filepath.Join(dir, "old.sqlite"), 7)
+	rolledBack := openTestDBAt(t, filepath.Join(dir, "rolled-back.sqlite"), -1)
+	if _, err := rolledBack.Exec("INSERT INTO goose_db_version (version_id, is_applied) VALUES (10, 0)"); err != nil {
+		t.Fatal(err)
+	}
+	other, err := sql.Open("sqlite3", filepath.Join(dir, "other.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.Exec("CREATE TABLE notes (id INTEGER)"); err != nil {
+		t.Fatal(err)
+	}
+	other.Close()
+
+	tests := []struct {
+		file    string
+		wantErr string
+	}{
+		{file: "latest.sqlite"},
+		{file: "old.sqlite", wantErr: "at migration 7"},
+		{file: "rolled-back.sqlite", wantErr: "at migration 9"},
+		{file: "other.sqlite", wantErr: "not a negentropy database"},
+		{file: "missing.sqlite", wantErr: "no such file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			err := validateBackup(ctx, current, filepath.Join(dir, tt.file))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	safety := filepath.Join(dir, "pre-restore.sqlite")
+	if err := restoreDB(ctx, current, filepath.Join(dir, "old.sqlite"), safety); err == nil {
+		t.Fatal("restored a backup from before the last migration")
+	}
+	if _, err := os.Stat(safety); !os.IsNotExist(err) {
+		t.Errorf("saved the database for a restore that didn't happen: %v", err)
+	}
+	if err := restoreDB(ctx, current, filepath.Join(dir, "latest.sqlite"), safety); err != nil {
+		t.Fatal(err)
+	}
+	if err := validateBackup(ctx, current, safety); err != nil {
+		t.Errorf("the database before the restore wasn't saved: %v", err)
+	}
+	tasks, err := db.New(current).GetTasks(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 2 || tasks[1].Name != "from backup" {
+		t.Errorf("tasks after restore = %+v", tasks)
+	}
+}
+
+func TestRotateDailyBackupDay(t *testing.T) {
+	loc, start := dayLocation, dayStart
+	t.Cleanup(func() { dayLocation, dayStart = loc, start })
+	dayLocation, dayStart = time.UTC, 4*time.Hour
+
+	sqldb, _ := openTestDB(t)
+	dir := t.TempDir()
+	// still the 10th with the day starting at 4
+	path, err := rotateDailyBackup(context.Background(), sqldb, dir, 3, time.Date(2026, 3, 11, 2, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(dir, dailyBackupPrefix+"2026-03-10.sqlite"); path != want {
+		t.Errorf("backed up to %s, want %s", path, want)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// reloads today's totals, planned blocks and streaks, called whenever a session starts, ends or is split
+func (m model) refreshToday() model {
+	stats, err := loadTodayStats(context.Background(), m.db, time.Now())
+	if err != nil {
+		log.Printf("couldn't load today's sessions: %v", err)
+		return m
+	}
+	if !stats.day.Equal(m.today.day) {
+		m.capWarned = false
+		m.capReached = false
+	}
+	m.today = stats
+	blocks, err := loadPlannedBlocks(context.Background(), m.db, stats.day, stats.day.AddDate(0, 0, 1))
+	if err != nil {
+		log.Printf("couldn't load planned blocks: %v", err)
+	}
+	m.plannedToday = blocks
+	return m.markTargetsMet(time.Now()).refreshProgress()
+}
+
+// time left under MaxProductivityHours, ok is false when no cap is set
+func (m model) capRemaining(now time.Time) (time.Duration, bool) {
+	if m.productivityCap <= 0 {
+		return 0, false
+	}
+	return m.productivityCap - m.today.total(now), true
+}
+
+// warns once when the cap gets close and once when it's reached, checked on every stopwatch tick
+func (m model) checkCap(now time.Time) model {
+	if !startOfDay(now).Equal(m.today.day) {
+		m = m.refreshToday()
+	}
+	remaining, ok := m.capRemaining(now)
+	if !ok || m.state != TimerRunning {
+		return m
+	}
+	switch {
+	case remaining <= 0 && !m.capReached:
+		m.capReached = true
+		m.capWarned = true
+		m.StatusQuote = fmt.Sprintf("Daily cap of %s reached, time to call it a day", formatDuration(m.productivityCap))
+	case remaining <= m.capWarnBefore && !m.capWarned:
+		m.capWarned = true
+		m.StatusQuote = fmt.Sprintf("Only %s left of today's %s cap", formatDuration(remaining), formatDuration(m.productivityCap))
+	}
+	return m
+}
+
+// refuses to start a session once the cap is used up, if enforce_productivity_cap is set
+func (m model) capAllowsStart(now time.Time) bool {
+	remaining, ok := m.capRemaining(now)
+	return !ok || !m.enforceCap || remaining > 0
+}
+
+func (m model) capView() string {
+	now := time.Now()
+	remaining, ok := m.capRemaining(now)
+	if !ok {
+		return "today: " + formatDuration(m.today.total(now))
+	}
+	if remaining <= 0 {
+		return fmt.Sprintf("today: %s / %s (over by %s)", formatDuration(m.today.total(now)), formatDuration(m.productivityCap), formatDuration(-remaining))
+	}
+	return fmt.Sprintf("today: %s / %s (%s left)", formatDuration(m.today.total(now)), formatDuration(m.productivityCap), formatDuration(remaining))
+}
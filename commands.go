@@ -0,0 +1,670 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/chee-zer/negentropy/daemon"
+	db "github.com/chee-zer/negentropy/database/sqlc"
+	"github.com/chee-zer/negentropy/ical"
+	"github.com/chee-zer/negentropy/importer"
+)
+
+// everything a subcommand needs, the TUI gets the same pieces through NewModel
+type app struct {
+	sqldb   *sql.DB
+	queries *db.Queries
+	cfg     UserConfig
+	events  *dispatcher
+	// the daemon and serve retry queued deliveries while they run
+	webhooks *webhookSender
+}
+
+type command struct {
+	name  string
+	usage string
+	run   func(a app, args []string) error
+}
+
+// `negentropy <command> [flags]`, without a command the TUI is started
+func getCommands() []command {
+	return []command{
+		{name: "entropy", usage: "entropy [--from YYYY-MM-DD] [--to YYYY-MM-DD]\tentropy analysis report", run: runEntropy},
+		{name: "commits", usage: "commits [--from YYYY-MM-DD] [--to YYYY-MM-DD]\tcommits of the git_repos per task and session", run: runCommits},
+		{name: "goals", usage: "goals\tstreaks and weekly goal progress", run: runGoals},
+		{name: "heatmap", usage: "heatmap [--year YYYY] [--target]\tyear at a glance, by hours or target completion", run: runHeatmap},
+		{name: "export", usage: "export [--from] [--to] [--format csv|json|ndjson] [--out file]\tdump sessions with task names", run: runExport},
+		{name: "ics", usage: "ics [--from] [--to] [--per-task] [--out path]\tsessions as an iCalendar file, or one per task in a directory", run: runICS},
+		{name: "plan", usage: "plan [--date YYYY-MM-DD] | plan import [--dry-run] [--from YYYY-MM-DD] [--days n] <file.ics>\tplanned vs actual, or plan from a calendar", run: runPlan},
+		{name: "import", usage: "import --format toggl|clockify|timewarrior [--dry-run] [--date-format DD/MM/YYYY] <file>\timport another tracker's export", run: runImport},
+		{name: "backup", usage: "backup [--out file]\tconsistent snapshot of the database, safe while the TUI runs", run: runBackup},
+		{name: "restore", usage: "restore <file>\treplace the database with a backup, the current one is backed up first", run: runRestore},
+		{name: "goal", usage: "goal <task> <duration>\tset a weekly goal like 10h, 0 removes it", run: runGoal},
+		{name: "start", usage: "start [task]\tstart a session, the task defaults to the one mapped to this directory", run: runStart},
+		{name: "stop", usage: "stop\tstop the running session", run: runStop},
+		{name: "status", usage: "status [--format '{task} {elapsed}'] [--idle text]\tone line for tmux, waybar or a shell prompt", run: runStatus},
+		{name: "daemon", usage: "daemon\tkeep the timer running in the background, the TUI attaches to it", run: runDaemon},
+		{name: "serve", usage: "serve [--addr host:port]\tHTTP/JSON api on localhost for plugins and macro pads", run: runServe},
+		{name: "sync", usage: "sync [--dir path]\tmerge tasks and sessions with other devices through a shared folder", run: runSync},
+	}
+}
+
+func runCommand(a app, args []string) error {
+	for _, c := range getCommands() {
+		if c.name == args[0] {
+			return c.run(a, args[1:])
+		}
+	}
+	printUsage()
+	return fmt.Errorf("unknown command %q", args[0])
+}
+
+func printUsage() {
+	fmt.Fprintln(os.Stderr, "usage: negentropy [command]\n\ncommands:")
+	w := tabwriter.NewWriter(os.Stderr, 0, 4, 2, ' ', 0)
+	for _, c := range getCommands() {
+		fmt.Fprintf(w, "  %s\n", c.usage)
+	}
+	w.Flush()
+}
+
+// --from/--to flags shared by the reports, to is inclusive on the command line
+// and returned exclusive (start of the next day)
+func dateRangeFlags(fs *flag.FlagSet, defaultDays int) func() (time.Time, time.Time, error) {
+	from := fs.String("from", "", "first day, YYYY-MM-DD")
+	to := fs.String("to", "", "last day, YYYY-MM-DD (default today)")
+	return func() (time.Time, time.Time, error) {
+		today := startOfDay(time.Now())
+		end, err := parseDate(*to, today)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		start, err := parseDate(*from, end.AddDate(0, 0, -defaultDays+1))
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		end = end.AddDate(0, 0, 1)
+		if !start.Before(end) {
+			return time.Time{}, time.Time{}, fmt.Errorf("--from must not be after --to")
+		}
+		return start, end, nil
+	}
+}
+
+func runEntropy(a app, args []string) error {
+	fs := flag.NewFlagSet("entropy", flag.ExitOnError)
+	dateRange := dateRangeFlags(fs, 30)
+	fs.Parse(args)
+	from, to, err := dateRange()
+	if err != nil {
+		return err
+	}
+
+	taskMap, _, err := GetTaskMap(a.queries)
+	if err != nil {
+		return err
+	}
+	spans, err := loadSpans(context.Background(), a.queries, from, to)
+	if err != nil {
+		return err
+	}
+	buildEntropyReport(spans, taskMap, from, to).Render(os.Stdout)
+	return nil
+}
+
+func runCommits(a app, args []string) error {
+	fs := flag.NewFlagSet("commits", flag.ExitOnError)
+	dateRange := dateRangeFlags(fs, 7)
+	fs.Parse(args)
+	from, to, err := dateRange()
+	if err != nil {
+		return err
+	}
+	if len(a.cfg.GitRepos) == 0 {
+		return fmt.Errorf("no repos to read, add them to git_repos in the config")
+	}
+
+	taskMap, _, err := GetTaskMap(a.queries)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	spans, err := loadSpans(ctx, a.queries, from, to)
+	if err != nil {
+		return err
+	}
+	commits := loadCommits(ctx, a.cfg.GitRepos, a.cfg.GitAuthor, from, to)
+	buildCommitReport(spans, taskMap, commits, from, to).Render(os.Stdout)
+	return nil
+}
+
+// finds a task by name (case insensitive) or id
+func findTask(tasks []db.Task, nameOrID string) (db.Task, error) {
+	for _, t := range tasks {
+		if strings.EqualFold(t.Name, nameOrID) {
+			return t, nil
+		}
+	}
+	if id, err := strconv.ParseInt(nameOrID, 10, 64); err == nil {
+		for _, t := range tasks {
+			if t.ID == id {
+				return t, nil
+			}
+		}
+	}
+	return db.Task{}, fmt.Errorf("no task named %q", nameOrID)
+}
+
+func runGoals(a app, args []string) error {
+	_, tasks, err := GetTaskMap(a.queries)
+	if err != nil {
+		return err
+	}
+	p, err := loadProgress(context.Background(), a.queries, tasks, time.Now())
+	if err != nil {
+		return err
+	}
+	renderGoals(os.Stdout, p, tasks)
+	return nil
+}
+
+func runGoal(a app, args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: negentropy goal <task> <duration>")
+	}
+	_, tasks, err := GetTaskMap(a.queries)
+	if err != nil {
+		return err
+	}
+	task, err := findTask(tasks, args[0])
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	if args[1] == "0" {
+		return a.queries.DeleteGoal(ctx, task.ID)
+	}
+	weekly, err := time.ParseDuration(args[1])
+	if err != nil || weekly <= 0 {
+		return fmt.Errorf("invalid duration %q, use something like 10h or 90m", args[1])
+	}
+	_, err = a.queries.SetGoal(ctx, db.SetGoalParams{TaskID: task.ID, WeeklySeconds: int64(weekly.Seconds())})
+	if err == nil {
+		fmt.Printf("weekly goal for %s: %s\n", task.Name, formatDuration(weekly))
+	}
+	return err
+}
+
+func runStart(a app, args []string) error {
+	taskMap, tasks, err := GetTaskMap(a.queries)
+	if err != nil {
+		return err
+	}
+	var task db.Task
+	why := ""
+	switch len(args) {
+	case 0:
+		dir, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		var ok bool
+		task, why, ok = taskForDir(context.Background(), dir, a.cfg.ProjectTasks, a.cfg.GitRepos, tasks)
+		if !ok {
+			return fmt.Errorf("no task for %s, name one or map the directory in project_tasks", dir)
+		}
+		why = " (" + why + ")"
+	case 1:
+		if task, err = findTask(tasks, args[0]); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("usage: negentropy start [task]")
+	}
+	timer, _ := connectTimer(a.queries, a.cfg.DaemonSocket, a.events)
+	if c, ok := timer.(io.Closer); ok {
+		defer c.Close()
+	}
+	status, err := timer.Start(context.Background(), task.ID, time.Now())
+	if errors.Is(err, daemon.ErrRunning) {
+		return fmt.Errorf("%s is already running, stop it first", taskName(taskMap, status.Session.TaskID))
+	}
+	if err == nil {
+		fmt.Printf("started %s%s\n", task.Name, why)
+	}
+	return err
+}
+
+func runStop(a app, args []string) error {
+	timer, _ := connectTimer(a.queries, a.cfg.DaemonSocket, a.events)
+	if c, ok := timer.(io.Closer); ok {
+		defer c.Close()
+	}
+	before, err := timer.Status(context.Background())
+	if err != nil {
+		return err
+	}
+	if _, err := timer.Stop(context.Background(), time.Now()); err != nil {
+		return err
+	}
+	start, err := parseTimestamp(before.Session.StartTime)
+	if err != nil {
+		return err
+	}
+	taskMap, _, err := GetTaskMap(a.queries)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("stopped %s after %s\n", taskName(taskMap, before.Session.TaskID), formatDuration(time.Since(start)))
+	return nil
+}
+
+// status without the rest of main: the database opened read only, no log file, hooks or
+// webhooks. Status bars run it every second, from whatever directory they're in
+func statusMain(args []string) error {
+	cfgFile, err := configPath()
+	if err != nil {
+		return err
+	}
+	dbFile, err := databasePath()
+	if err != nil {
+		return err
+	}
+	// a broken config is the TUI's to report, the defaults do for a status line
+	cfg, _ := GetConfig(cfgFile)
+	dayLocation, dayStart = cfg.Location, cfg.DayStart
+	sqldb, err := sql.Open("sqlite3", (&url.URL{Scheme: "file", Path: dbFile, RawQuery: "mode=ro"}).String())
+	if err != nil {
+		return err
+	}
+	defer sqldb.Close()
+	return runStatus(app{sqldb: sqldb, queries: db.New(sqldb), cfg: cfg}, args)
+}
+
+// read only and without the daemon, status bars call this every second
+func runStatus(a app, args []string) error {
+	fs := flag.NewFlagSet("status", flag.ExitOnError)
+	format := fs.String("format", "{task} {elapsed}", "line while a session runs, placeholders: {"+strings.Join(statusFields, "} {")+"}")
+	idleFormat := fs.String("idle", "", "line when nothing runs, same placeholders")
+	fs.Parse(args)
+
+	values, running, err := loadStatusValues(context.Background(), a.queries, time.Now())
+	if err != nil {
+		return err
+	}
+	line, err := renderStatus(*format, values)
+	if err != nil {
+		return err
+	}
+	idleLine, err := renderStatus(*idleFormat, values)
+	if err != nil {
+		return err
+	}
+	if !running {
+		line = idleLine
+	}
+	fmt.Println(line)
+	return nil
+}
+
+// serves the timer on the socket until interrupted
+func runDaemon(a app, args []string) error {
+	l, err := daemon.Listen(a.cfg.DaemonSocket)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(a.cfg.DaemonSocket)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go a.webhooks.retry(ctx)
+	fmt.Fprintf(os.Stderr, "negentropy daemon listening on %s\n", a.cfg.DaemonSocket)
+	return daemon.Serve(ctx, l, localTimer{q: a.queries, events: a.events})
+}
+
+func runServe(a app, args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", a.cfg.HTTPAddr, "address to listen on")
+	fs.Parse(args)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go a.webhooks.retry(ctx)
+	fmt.Fprintf(os.Stderr, "negentropy api listening on http://%s\n", *addr)
+	return serveHTTP(ctx, *addr, httpAPI{q: a.queries, socket: a.cfg.DaemonSocket, token: a.cfg.HTTPToken, events: a.events, mu: &sync.Mutex{}})
+}
+
+func runSync(a app, args []string) error {
+	fs := flag.NewFlagSet("sync", flag.ExitOnError)
+	dir := fs.String("dir", a.cfg.SyncDir, "folder shared between devices, like a syncthing or dropbox folder")
+	fs.Parse(args)
+	if *dir == "" {
+		return fmt.Errorf("no sync folder, set sync_dir in the config or pass --dir")
+	}
+
+	devicePath, err := syncDevicePath()
+	if err != nil {
+		return err
+	}
+	device, err := syncDeviceID(devicePath)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	tx, err := a.sqldb.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	stats, err := syncFolder(ctx, a.queries.WithTx(tx), *dir, device)
+	if errors.Is(err, errSyncLogAhead) {
+		return fmt.Errorf("%w\ndelete %s to sync this database as a new device", err, devicePath)
+	}
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	emitTaskCreates(ctx, a.queries, a.events, stats.CreatedTasks, time.Now())
+	// the changes are safe in the db, if this fails the next sync writes them
+	if err := flushSyncLog(ctx, a.queries, *dir); err != nil {
+		return fmt.Errorf("writing the sync log: %w", err)
+	}
+
+	fmt.Printf("device %s: sent %d changes, applied %d of %d from other devices\n", stats.Device, stats.Sent, stats.Applied, stats.Received)
+	if stats.Skipped > 0 {
+		fmt.Printf("couldn't apply %d changes yet, the next sync tries again, see debug.log\n", stats.Skipped)
+	}
+	if stats.Trimmed > 0 || stats.Removed > 0 {
+		fmt.Printf("overlapping sessions: %d trimmed, %d removed\n", stats.Trimmed, stats.Removed)
+	}
+	return nil
+}
+
+func runHeatmap(a app, args []string) error {
+	fs := flag.NewFlagSet("heatmap", flag.ExitOnError)
+	year := fs.Int("year", 0, "calendar year, default is the last 12 months")
+	byTarget := fs.Bool("target", false, "shade by daily target completion instead of hours")
+	fs.Parse(args)
+
+	from, to := heatmapRange(startOfDay(time.Now()).AddDate(0, 0, 1))
+	if *year != 0 {
+		from = dayAt(*year, time.January, 1)
+		to = from.AddDate(1, 0, 0)
+	}
+	mode := heatHours
+	if *byTarget {
+		mode = heatTarget
+	}
+	_, tasks, err := GetTaskMap(a.queries)
+	if err != nil {
+		return err
+	}
+	heatmap, err := loadHeatmap(context.Background(), a.queries, tasks, from, to, mode)
+	if err != nil {
+		return err
+	}
+	fmt.Print(heatmap)
+	return nil
+}
+
+func runExport(a app, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	dateRange := dateRangeFlags(fs, 30)
+	format := fs.String("format", "csv", "csv, json or ndjson")
+	out := fs.String("out", "", "file to write, default stdout")
+	fs.Parse(args)
+	from, to, err := dateRange()
+	if err != nil {
+		return err
+	}
+	if *format != "csv" && *format != "json" && *format != "ndjson" {
+		return fmt.Errorf("unknown format %q, use csv, json or ndjson", *format)
+	}
+
+	taskMap, _, err := GetTaskMap(a.queries)
+	if err != nil {
+		return err
+	}
+	sessions, err := a.queries.GetSessionsInRange(context.Background(), db.GetSessionsInRangeParams{
+		RangeStart: formatTimestamp(from),
+		RangeEnd:   formatTimestamp(to),
+	})
+	if err != nil {
+		return err
+	}
+	rows, err := toExportRows(sessions, taskMap, time.Now())
+	if err != nil {
+		return err
+	}
+
+	w := os.Stdout
+	if *out != "" {
+		if w, err = os.Create(*out); err != nil {
+			return err
+		}
+		defer w.Close()
+	}
+	return writeExport(w, rows, *format)
+}
+
+func runImport(a app, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	format := fs.String("format", "", "toggl, clockify or timewarrior")
+	dryRun := fs.Bool("dry-run", false, "only show what would be imported")
+	dateFormat := fs.String("date-format", "", "date format of toggl and clockify exports, "+strings.Join(importer.DateFormats, ", ")+". Found from the file by default")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: negentropy import --format toggl|clockify|timewarrior [--dry-run] [--date-format DD/MM/YYYY] <file>")
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	entries, err := importer.Parse(*format, f, dayLocation, *dateFormat)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	tx, err := a.sqldb.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	stats, err := importEntries(ctx, a.queries.WithTx(tx), entries, *dryRun, os.Stdout)
+	if err != nil {
+		return err
+	}
+	if !*dryRun {
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		emitTaskCreates(ctx, a.queries, a.events, stats.CreatedIDs, time.Now())
+	}
+
+	verb := "imported"
+	if *dryRun {
+		verb = "would import"
+	}
+	fmt.Printf("%s %d sessions, %d duplicates and %d empty entries skipped\n", verb, stats.Imported, stats.Duplicates, stats.Skipped)
+	if len(stats.CreatedTasks) > 0 {
+		fmt.Printf("new tasks: %s\n", strings.Join(stats.CreatedTasks, ", "))
+	}
+	return nil
+}
+
+func runICS(a app, args []string) error {
+	fs := flag.NewFlagSet("ics", flag.ExitOnError)
+	dateRange := dateRangeFlags(fs, 30)
+	perTask := fs.Bool("per-task", false, "write one calendar per task into the --out directory")
+	out := fs.String("out", "", "file to write (directory with --per-task), default stdout (current directory)")
+	fs.Parse(args)
+	from, to, err := dateRange()
+	if err != nil {
+		return err
+	}
+
+	taskMap, tasks, err := GetTaskMap(a.queries)
+	if err != nil {
+		return err
+	}
+	sessions, err := a.queries.GetSessionsInRange(context.Background(), db.GetSessionsInRangeParams{
+		RangeStart: formatTimestamp(from),
+		RangeEnd:   formatTimestamp(to),
+	})
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	events, err := sessionEvents(sessions, taskMap, now)
+	if err != nil {
+		return err
+	}
+
+	if !*perTask {
+		cal := ical.Calendar{Name: "negentropy"}
+		for _, evs := range events {
+			cal.Events = append(cal.Events, evs...)
+		}
+		sort.Slice(cal.Events, func(i, j int) bool { return cal.Events[i].Start.Before(cal.Events[j].Start) })
+		if *out == "" {
+			return cal.Encode(os.Stdout, now)
+		}
+		return writeCalendar(*out, cal, now)
+	}
+
+	dir := *out
+	if dir == "" {
+		dir = "."
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for _, t := range tasks {
+		if len(events[t.ID]) == 0 {
+			continue
+		}
+		path := filepath.Join(dir, fmt.Sprintf("negentropy-%d.ics", t.ID))
+		if err := writeCalendar(path, calendarFor(t, events[t.ID]), now); err != nil {
+			return err
+		}
+		fmt.Printf("%s: %d events -> %s\n", t.Name, len(events[t.ID]), path)
+	}
+	return nil
+}
+
+func writeCalendar(path string, cal ical.Calendar, stamp time.Time) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := cal.Encode(f, stamp); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func runPlan(a app, args []string) error {
+	ctx := context.Background()
+	if len(args) > 0 && args[0] == "import" {
+		fs := flag.NewFlagSet("plan import", flag.ExitOnError)
+		dryRun := fs.Bool("dry-run", false, "only show what would be planned")
+		from := fs.String("from", "", "first day recurring events are planned on, YYYY-MM-DD (default today)")
+		days := fs.Int("days", 28, "number of days recurring events are planned for")
+		fs.Parse(args[1:])
+		if fs.NArg() != 1 {
+			return fmt.Errorf("usage: negentropy plan import [--dry-run] [--from YYYY-MM-DD] [--days n] <file.ics>")
+		}
+		day, err := parseDate(*from, startOfDay(time.Now()))
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		events, err := ical.Parse(f, dayLocation, day, day.AddDate(0, 0, *days))
+		if err != nil {
+			return err
+		}
+		n, unmatched, err := importPlan(ctx, a.queries, events, a.cfg.PlanRules, *dryRun, os.Stdout)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("planned %d blocks, %d events didn't match a task\n", n, len(unmatched))
+		for _, u := range unmatched {
+			fmt.Printf("  unmatched: %s\n", u)
+		}
+		return nil
+	}
+
+	fs := flag.NewFlagSet("plan", flag.ExitOnError)
+	date := fs.String("date", "", "day to compare, YYYY-MM-DD (default today)")
+	fs.Parse(args)
+	day, err := parseDate(*date, startOfDay(time.Now()))
+	if err != nil {
+		return err
+	}
+	taskMap, _, err := GetTaskMap(a.queries)
+	if err != nil {
+		return err
+	}
+	blocks, err := loadPlannedBlocks(ctx, a.queries, day, day.AddDate(0, 0, 1))
+	if err != nil {
+		return err
+	}
+	spans, err := loadSpans(ctx, a.queries, day, day.AddDate(0, 0, 1))
+	if err != nil {
+		return err
+	}
+	renderPlanReport(os.Stdout, blocks, spans, taskMap, day)
+	return nil
+}
+
+func runBackup(a app, args []string) error {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	out := fs.String("out", "", "backup file, default a timestamped file in backup_dir")
+	fs.Parse(args)
+	path := *out
+	if path == "" {
+		path = filepath.Join(a.cfg.BackupDir, "appdb-"+time.Now().Format("20060102-150405")+".sqlite")
+	}
+	if err := backupDB(context.Background(), a.sqldb, path); err != nil {
+		return err
+	}
+	fmt.Println("backed up to", path)
+	return nil
+}
+
+func runRestore(a app, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: negentropy restore <file>")
+	}
+	safety := filepath.Join(a.cfg.BackupDir, "pre-restore-"+time.Now().Format("20060102-150405")+".sqlite")
+	if err := restoreDB(context.Background(), a.sqldb, args[0], safety); err != nil {
+		return err
+	}
+	fmt.Printf("restored %s, the previous database was saved to %s\n", args[0], safety)
+	return nil
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	db "github.com/chee-zer/negentropy/database/sqlc"
+	"github.com/chee-zer/negentropy/gitlog"
+)
+
+// a commit made this long after a session ended still belongs to it, committing often comes
+// right after stopping the timer
+const commitGrace = 10 * time.Minute
+
+// a repository whose commits are matched with sessions, see git_repos in the config
+type gitRepo struct {
+	Path string `json:"path"`
+	Task string `json:"task"` // picked when working inside the repo, optional, see projects.go
+}
+
+func validateGitRepos(repos []gitRepo) error {
+	for _, r := range repos {
+		if r.Path == "" {
+			return fmt.Errorf("git repo without a path")
+		}
+	}
+	return nil
+}
+
+// commits of every repo in [from, to), oldest first. They're filtered by author, or by each
+// repo's user.email when author is empty. Repos that can't be read, or have no user.email to go
+// by, are logged and left out
+func loadCommits(ctx context.Context, repos []gitRepo, author string, from, to time.Time) []gitlog.Commit {
+	var all []gitlog.Commit
+	for _, r := range repos {
+		who := author
+		if who == "" {
+			email, err := gitlog.Email(ctx, r.Path)
+			if err != nil {
+				log.Printf("commits of %s: %v", r.Path, err)
+				continue
+			}
+			// no filter would count everyone's commits
+			if email == "" {
+				log.Printf("commits of %s: no user.email, set it in the repo or set git_author", r.Path)
+				continue
+			}
+			who = email
+		}
+		commits, err := gitlog.Log(ctx, r.Path, who, from, to)
+		if err != nil {
+			log.Printf("commits of %s: %v", r.Path, err)
+			continue
+		}
+		all = append(all, commits...)
+	}
+	slices.SortStableFunc(all, func(a, b gitlog.Commit) int { return a.Time.Compare(b.Time) })
+	return all
+}
+
+// the commits made in each span, by index in spans. Commits made outside every session come
+// back as untracked, that's often work that wasn't tracked
+func matchCommits(spans []span, commits []gitlog.Commit) (map[int][]gitlog.Commit, []gitlog.Commit) {
+	matched := make(map[int][]gitlog.Commit)
+	var untracked []gitlog.Commit
+	for _, c := range commits {
+		if i := commitSpan(spans, c.Time); i >= 0 {
+			matched[i] = append(matched[i], c)
+		} else {
+			untracked = append(untracked, c)
+		}
+	}
+	return matched, untracked
+}
+
+// the work session t is in, the one that started last if sessions overlap, or else the one
+// that ended last within commitGrace before t. -1 if there's none
+func commitSpan(spans []span, t time.Time) int {
+	in, after := -1, -1
+	for i, s := range spans {
+		if s.isEntropy() || s.isBreak() {
+			continue
+		}
+		if !t.Before(s.Start) && t.Before(s.End) && (in < 0 || s.Start.After(spans[in].Start)) {
+			in = i
+		}
+		if !t.Before(s.End) && t.Sub(s.End) <= commitGrace && (after < 0 || s.End.After(spans[after].End)) {
+			after = i
+		}
+	}
+	if in >= 0 {
+		return in
+	}
+	return after
+}
+
+// " · task" of the session c was made in, empty for untracked commits
+func commitTask(spans []span, tasks map[int64]db.Task, c gitlog.Commit) string {
+	if i := commitSpan(spans, c.Time); i >= 0 {
+		if t, ok := tasks[spans[i].TaskID]; ok {
+			return " · " + t.Name
+		}
+	}
+	return ""
+}
+
+// commits per task and session over a date range
+type commitReport struct {
+	From      time.Time
+	To        time.Time
+	Tasks     []taskCommits
+	Untracked []gitlog.Commit
+}
+
+type taskCommits struct {
+	Name     string
+	Tracked  time.Duration
+	Commits  int
+	Sessions []sessionCommits
+}
+
+type sessionCommits struct {
+	span
+	Commits []gitlog.Commit
+}
+
+// spans must already be clipped to [from, to), commits sorted by time
+func buildCommitReport(spans []span, tasks map[int64]db.Task, commits []gitlog.Commit, from, to time.Time) commitReport {
+	r := commitReport{From: from, To: to}
+	matched, untracked := matchCommits(spans, commits)
+	r.Untracked = untracked
+	perTask := make(map[int64]*taskCommits)
+	for i, s := range spans {
+		if s.isEntropy() || s.isBreak() {
+			continue
+		}
+		t, ok := perTask[s.TaskID]
+		if !ok {
+			name := fmt.Sprintf("#%d (deleted)", s.TaskID)
+			if task, ok := tasks[s.TaskID]; ok {
+				name = task.Name
+			}
+			t = &taskCommits{Name: name}
+			perTask[s.TaskID] = t
+		}
+		t.Tracked += s.duration()
+		if len(matched[i]) > 0 {
+			t.Commits += len(matched[i])
+			t.Sessions = append(t.Sessions, sessionCommits{span: s, Commits: matched[i]})
+		}
+	}
+	for _, t := range perTask {
+		if t.Commits > 0 {
+			r.Tasks = append(r.Tasks, *t)
+		}
+	}
+	slices.SortFunc(r.Tasks, func(a, b taskCommits) int {
+		if a.Commits != b.Commits {
+			return b.Commits - a.Commits
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+	return r
+}
+
+func (r commitReport) Render(w io.Writer) {
+	fmt.Fprintf(w, "Commits %s → %s\n", r.From.Format(dateLayout), r.To.AddDate(0, 0, -1).Format(dateLayout))
+	if len(r.Tasks) == 0 && len(r.Untracked) == 0 {
+		fmt.Fprintln(w, "\nNo commits from the configured repos in this range")
+		return
+	}
+	for _, t := range r.Tasks {
+		noun := "commits"
+		if t.Commits == 1 {
+			noun = "commit"
+		}
+		fmt.Fprintf(w, "\n  %-20s %d %s in %s\n", t.Name, t.Commits, noun, formatDuration(t.Tracked))
+		for _, s := range t.Sessions {
+			end := s.End.Format("15:04")
+			if !startOfDay(s.End).Equal(startOfDay(s.Start)) {
+				end = s.End.Format("Mon 2006-01-02 15:04")
+			}
+			fmt.Fprintf(w, "    %s - %s  %s\n", s.Start.Format("Mon 2006-01-02 15:04"), end, formatDuration(s.duration()))
+			for _, c := range s.Commits {
+				fmt.Fprintf(w, "      %s\n", commitLine(c, "15:04"))
+			}
+		}
+	}
+	if len(r.Untracked) > 0 {
+		fmt.Fprintf(w, "\n  Made outside any session (%d)\n", len(r.Untracked))
+		for _, c := range r.Untracked {
+			fmt.Fprintf(w, "    %s\n", commitLine(c, "Mon 2006-01-02 15:04"))
+		}
+	}
+}
+
+func commitLine(c gitlog.Commit, layout string) string {
+	return fmt.Sprintf("%s  %-12s %s  %s", c.Time.In(dayLocation).Format(layout), c.RepoName(), c.ShortHash(), c.Subject)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+// a repository with one commit by me@example.com and one by someone else, and no user.email
+// set anywhere
+func testRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("no git")
+	}
+	t.Setenv("GIT_CONFIG_GLOBAL", "/dev/null")
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	dir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		if out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	git("init", "-q")
+	for _, email := range []string{"me@example.com", "them@example.com"} {
+		git("-c", "user.name=x", "-c", "user.email="+email, "commit", "-q", "--allow-empty", "-m", "work of "+email)
+	}
+	return dir
+}
+
+func TestLoadCommitsAuthor(t *testing.T) {
+	repos := []gitRepo{{Path: testRepo(t)}}
+	ctx := context.Background()
+	from, to := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+
+	if got := loadCommits(ctx, repos, "me@example.com", from, to); len(got) != 1 || got[0].Author != "me@example.com" {
+		t.Errorf("commits by git_author = %+v", got)
+	}
+	// nobody to filter by, counting everyone's commits would be wrong
+	if got := loadCommits(ctx, repos, "", from, to); len(got) != 0 {
+		t.Errorf("commits without user.email = %+v", got)
+	}
+}
+
+func TestTimelineCommits(t *testing.T) {
+	_, q := openTestDB(t)
+	m := NewModel(q, UserConfig{Notifier: "none", GitAuthor: "me@example.com", GitRepos: []gitRepo{{Path: testRepo(t)}}}, nil, localTimer{q: q}, false, &dispatcher{})
+
+	m, cmd := m.openTimeline()
+	if m.view != timelineView || !m.timelineLoading || cmd == nil {
+		t.Fatalf("view %v loading %v, want the timeline waiting for commits", m.view, m.timelineLoading)
+	}
+	msg, ok := cmd().(timelineCommitsMsg)
+	if !ok || len(msg.commits) != 1 {
+		t.Fatalf("loaded %+v", msg)
+	}
+
+	// commits of a day that was left are dropped
+	stale := m
+	stale.timelineDay = m.timelineDay.AddDate(0, 0, -1)
+	if next, _ := stale.Update(msg); !next.(model).timelineLoading {
+		t.Error("commits of another day were shown")
+	}
+
+	next, _ := m.Update(msg)
+	m = next.(model)
+	if m.timelineLoading || len(m.timelineCommits) != 1 {
+		t.Errorf("loading %v commits %+v", m.timelineLoading, m.timelineCommits)
+	}
+}
@@ -3,22 +3,72 @@ package main
 import (
 	"encoding/json"
 	"os"
+	"time"
 
 	"github.com/charmbracelet/bubbles/key"
+	"github.com/chee-zer/negentropy/daemon"
 )
 
 type UserConfig struct {
-	Keymap               keymap
-	MaxProductivityHours int
-	Theme                string
-	EnableAnimations     bool
+	Keymap                 keymap
+	MaxProductivityHours   int
+	CapWarningMinutes      int
+	EnforceProductivityCap bool
+	Theme                  string
+	EnableAnimations       bool
+	IdleThresholdMinutes   int
+	IdleSource             string
+	PlanRules              []planRule
+	BackupDir              string
+	BackupKeepDays         int
+	Location               *time.Location
+	DayStart               time.Duration
+	DaemonSocket           string
+	HTTPAddr               string
+	HTTPToken              string
+	Notifier               string
+	PomodoroMinutes        int
+	LongSessionMinutes     int
+	AlertBell              bool
+	AlertFlashTitle        bool
+	AlertWindowTitle       bool
+	Hooks                  map[string][]string
+	Webhooks               []webhookConfig
+	SyncDir                string
+	GitRepos               []gitRepo
+	GitAuthor              string
+	ProjectTasks           []projectTask
 }
 
 type rootConfig struct {
-	Keymap               keymapConfig `json:"keymap"`
-	MaxProductivityHours int          `json:"max_productivity_hours"`
-	Theme                string       `json:"theme"`
-	EnableAnimations     bool         `json:"enable_animations"`
+	Keymap                 keymapConfig        `json:"keymap"`
+	MaxProductivityHours   int                 `json:"max_productivity_hours"` // 0 disables the daily cap
+	CapWarningMinutes      int                 `json:"cap_warning_minutes"`    // warn this long before the cap is used up
+	EnforceProductivityCap bool                `json:"enforce_productivity_cap"`
+	Theme                  string              `json:"theme"`
+	EnableAnimations       bool                `json:"enable_animations"`
+	IdleThresholdMinutes   int                 `json:"idle_threshold_minutes"` // 0 turns idle detection off
+	IdleSource             string              `json:"idle_source"`            // auto, xprintidle, logind or none
+	PlanRules              []planRule          `json:"plan_rules"`
+	BackupDir              string              `json:"backup_dir"`           // empty is backups/ next to the database
+	BackupKeepDays         int                 `json:"backup_keep_days"`     // daily backups kept, 0 turns them off
+	Timezone               string              `json:"timezone"`             // IANA name for day boundaries, empty is the system zone
+	DayStart               string              `json:"day_start"`            // HH:MM when a new day starts, later than 00:00 for night owls
+	DaemonSocket           string              `json:"daemon_socket"`        // empty uses $XDG_RUNTIME_DIR/negentropy.sock
+	HTTPAddr               string              `json:"http_addr"`            // where `negentropy serve` listens
+	HTTPToken              string              `json:"http_token"`           // bearer token for the http api, needed for browsers
+	Notifier               string              `json:"notifier"`             // auto, notify-send, dbus or none
+	PomodoroMinutes        int                 `json:"pomodoro_minutes"`     // notify every this many minutes of a session, 0 turns it off
+	LongSessionMinutes     int                 `json:"long_session_minutes"` // notify once a session runs this long, 0 turns it off
+	AlertBell              bool                `json:"alert_bell"`           // ring the terminal bell on the same milestones
+	AlertFlashTitle        bool                `json:"alert_flash_title"`    // flash the window title on milestones
+	AlertWindowTitle       bool                `json:"alert_window_title"`   // running task and elapsed time in the window title
+	Hooks                  map[string][]string `json:"hooks"`                // shell commands per event name, see hooks.go
+	Webhooks               []webhookConfig     `json:"webhooks"`             // urls the session events are posted to, see webhooks.go
+	SyncDir                string              `json:"sync_dir"`             // folder shared between devices for `negentropy sync`
+	GitRepos               []gitRepo           `json:"git_repos"`            // repos whose commits are matched with sessions
+	GitAuthor              string              `json:"git_author"`           // email of your commits, empty uses each repo's user.email
+	ProjectTasks           []projectTask       `json:"project_tasks"`        // directories and git remotes mapped to tasks
 }
 
 type keymapConfig struct {
@@ -29,6 +79,8 @@ type keymapConfig struct {
 	DeleteTask     []string `json:"delete_task"`
 	CreateTask     []string `json:"create_task"`
 	ResetTimer     []string `json:"reset_timer"`
+	ToggleHeatmap  []string `json:"toggle_heatmap"`
+	ToggleTimeline []string `json:"toggle_timeline"`
 	Yes            []string `json:"yes"`
 	No             []string `json:"no"`
 }
@@ -43,15 +95,57 @@ func GetConfig(path string) (UserConfig, error) {
 		err = json.Unmarshal(data, &fileCfg)
 	}
 	//TODO: typos will be ignored, write a strict check later
-	return mapToUserConfig(fileCfg), err
+	cfg := mapToUserConfig(fileCfg)
+	if fileCfg.Timezone != "" {
+		loc, tzErr := time.LoadLocation(fileCfg.Timezone)
+		if tzErr != nil && err == nil {
+			err = tzErr
+		}
+		if tzErr == nil {
+			cfg.Location = loc
+		}
+	}
+	dayStart, dsErr := parseDayStart(fileCfg.DayStart)
+	if dsErr != nil && err == nil {
+		err = dsErr
+	}
+	cfg.DayStart = dayStart
+	if hookErr := validateHooks(cfg.Hooks); hookErr != nil && err == nil {
+		err = hookErr
+	}
+	if whErr := validateWebhooks(cfg.Webhooks); whErr != nil && err == nil {
+		err = whErr
+	}
+	if repoErr := validateGitRepos(cfg.GitRepos); repoErr != nil && err == nil {
+		err = repoErr
+	}
+	if projErr := validateProjectTasks(cfg.ProjectTasks); projErr != nil && err == nil {
+		err = projErr
+	}
+	if cfg.DaemonSocket == "" {
+		cfg.DaemonSocket = daemon.DefaultSocketPath()
+	}
+	return cfg, err
 }
 
 func defaultRootConfig() rootConfig {
 	return rootConfig{
 		Keymap:               defaultKeymapConfig(),
 		MaxProductivityHours: 8,
+		CapWarningMinutes:    30,
 		Theme:                "dark",
 		EnableAnimations:     true,
+		IdleThresholdMinutes: 10,
+		IdleSource:           "auto",
+		BackupKeepDays:       7,
+		DayStart:             "00:00",
+		HTTPAddr:             "127.0.0.1:7788",
+		Notifier:             "auto",
+		PomodoroMinutes:      25,
+		LongSessionMinutes:   120,
+		AlertBell:            true,
+		AlertFlashTitle:      true,
+		AlertWindowTitle:     true,
 	}
 }
 
@@ -64,6 +158,8 @@ func defaultKeymapConfig() keymapConfig {
 		CreateTask:     []string{"n"},
 		DeleteTask:     []string{"x"},
 		ResetTimer:     []string{"r"},
+		ToggleHeatmap:  []string{"m"},
+		ToggleTimeline: []string{"t"},
 		Yes:            []string{"y"},
 		No:             []string{"n"},
 	}
@@ -71,9 +167,32 @@ func defaultKeymapConfig() keymapConfig {
 
 func mapToUserConfig(cfg rootConfig) UserConfig {
 	return UserConfig{
-		MaxProductivityHours: cfg.MaxProductivityHours,
-		Theme:                cfg.Theme,
-		EnableAnimations:     cfg.EnableAnimations,
+		MaxProductivityHours:   cfg.MaxProductivityHours,
+		CapWarningMinutes:      cfg.CapWarningMinutes,
+		EnforceProductivityCap: cfg.EnforceProductivityCap,
+		Theme:                  cfg.Theme,
+		EnableAnimations:       cfg.EnableAnimations,
+		IdleThresholdMinutes:   cfg.IdleThresholdMinutes,
+		IdleSource:             cfg.IdleSource,
+		PlanRules:              cfg.PlanRules,
+		BackupDir:              cfg.BackupDir,
+		BackupKeepDays:         cfg.BackupKeepDays,
+		Location:               time.Local,
+		DaemonSocket:           cfg.DaemonSocket,
+		HTTPAddr:               cfg.HTTPAddr,
+		HTTPToken:              cfg.HTTPToken,
+		Notifier:               cfg.Notifier,
+		PomodoroMinutes:        cfg.PomodoroMinutes,
+		LongSessionMinutes:     cfg.LongSessionMinutes,
+		AlertBell:              cfg.AlertBell,
+		AlertFlashTitle:        cfg.AlertFlashTitle,
+		AlertWindowTitle:       cfg.AlertWindowTitle,
+		Hooks:                  cfg.Hooks,
+		Webhooks:               cfg.Webhooks,
+		SyncDir:                cfg.SyncDir,
+		GitRepos:               cfg.GitRepos,
+		GitAuthor:              cfg.GitAuthor,
+		ProjectTasks:           cfg.ProjectTasks,
 		Keymap: keymap{
 			StartStopTimer: key.NewBinding(
 				key.WithKeys(cfg.Keymap.StartStopTimer...),
@@ -103,6 +222,14 @@ func mapToUserConfig(cfg rootConfig) UserConfig {
 				key.WithKeys(cfg.Keymap.ResetTimer...),
 				key.WithHelp("r", "reset timer"),
 			),
+			ToggleHeatmap: key.NewBinding(
+				key.WithKeys(cfg.Keymap.ToggleHeatmap...),
+				key.WithHelp("m", "heatmap"),
+			),
+			ToggleTimeline: key.NewBinding(
+				key.WithKeys(cfg.Keymap.ToggleTimeline...),
+				key.WithHelp("t", "timeline"),
+			),
 			Yes: key.NewBinding(key.WithKeys(cfg.Keymap.Yes...)),
 			No:  key.NewBinding(key.WithKeys(cfg.Keymap.No...)),
 		},
@@ -0,0 +1,103 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"sync"
+	"time"
+)
+
+// a connection to a running daemon, safe to share between goroutines
+type Client struct {
+	path string
+	mu   sync.Mutex
+	conn net.Conn // nil after a failed call, the next one dials again
+	enc  *json.Encoder
+	dec  *json.Decoder
+}
+
+var _ Timer = (*Client)(nil)
+
+// connects to the daemon at path, fails fast if none is running
+func Dial(path string) (*Client, error) {
+	c := &Client{path: path}
+	if err := c.dial(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Client) dial() error {
+	conn, err := net.DialTimeout("unix", c.path, time.Second)
+	if err != nil {
+		return err
+	}
+	c.conn, c.enc, c.dec = conn, json.NewEncoder(conn), json.NewDecoder(conn)
+	return nil
+}
+
+// after a timeout or a broken read the stream is out of step with the daemon, the rest of
+// a late response would be read as the answer to the next request
+func (c *Client) drop() {
+	c.conn.Close()
+	c.conn = nil
+}
+
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+func (c *Client) Status(ctx context.Context) (Status, error) {
+	return c.call(ctx, request{Op: opStatus})
+}
+
+func (c *Client) Start(ctx context.Context, taskID int64, at time.Time) (Status, error) {
+	return c.call(ctx, request{Op: opStart, TaskID: taskID, At: at, Cause: Cause(ctx)})
+}
+
+func (c *Client) Stop(ctx context.Context, at time.Time) (Status, error) {
+	return c.call(ctx, request{Op: opStop, At: at, Cause: Cause(ctx)})
+}
+
+func (c *Client) Reset(ctx context.Context, at time.Time, reason string) (Status, error) {
+	return c.call(ctx, request{Op: opReset, At: at, Reason: reason, Cause: Cause(ctx)})
+}
+
+func (c *Client) call(ctx context.Context, req request) (Status, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		if err := c.dial(); err != nil {
+			return Status{}, err
+		}
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(5 * time.Second)
+	}
+	if err := c.conn.SetDeadline(deadline); err != nil {
+		c.drop()
+		return Status{}, err
+	}
+	if err := c.enc.Encode(req); err != nil {
+		c.drop()
+		return Status{}, err
+	}
+	var resp response
+	if err := c.dec.Decode(&resp); err != nil {
+		c.drop()
+		return Status{}, err
+	}
+	if resp.Error != "" {
+		return resp.Status, decodeError(resp.Error)
+	}
+	return resp.Status, nil
+}
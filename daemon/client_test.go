@@ -0,0 +1,66 @@
+package daemon
+
+import (
+	"context"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	db "github.com/chee-zer/negentropy/database/sqlc"
+)
+
+// answers Status with a session whose id is the number of the call, the first one slowly
+type slowTimer struct {
+	calls atomic.Int64
+}
+
+func (t *slowTimer) Status(ctx context.Context) (Status, error) {
+	n := t.calls.Add(1)
+	if n == 1 {
+		time.Sleep(200 * time.Millisecond)
+	}
+	return Status{Session: &db.Session{ID: n}}, nil
+}
+
+func (t *slowTimer) Start(ctx context.Context, taskID int64, at time.Time) (Status, error) {
+	return Status{}, nil
+}
+
+func (t *slowTimer) Stop(ctx context.Context, at time.Time) (Status, error) {
+	return Status{}, nil
+}
+
+func (t *slowTimer) Reset(ctx context.Context, at time.Time, reason string) (Status, error) {
+	return Status{}, nil
+}
+
+func TestClientRecoversAfterTimeout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "d.sock")
+	l, err := Listen(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	go Serve(ctx, l, &slowTimer{})
+
+	c, err := Dial(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	short, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, err := c.Status(short); err == nil {
+		t.Fatal("no timeout")
+	}
+	// a new connection, not the late answer to the first call
+	status, err := c.Status(ctx)
+	if err != nil {
+		t.Fatalf("call after the timeout: %v", err)
+	}
+	if got := status.Session.ID; got != 2 {
+		t.Errorf("got the answer to call %d, want 2", got)
+	}
+}
@@ -0,0 +1,89 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	db "github.com/chee-zer/negentropy/database/sqlc"
+)
+
+var (
+	ErrRunning    = errors.New("a session is already running")
+	ErrNotRunning = errors.New("no session is running")
+)
+
+// Session is nil when nothing is running
+type Status struct {
+	Session *db.Session `json:"session"`
+}
+
+func (s Status) Running() bool {
+	return s.Session != nil
+}
+
+// what can be done with the timer. The daemon implements it on the database and serves it,
+// Client implements it over the socket, so the UI doesn't care which one it talks to
+type Timer interface {
+	Status(ctx context.Context) (Status, error)
+	Start(ctx context.Context, taskID int64, at time.Time) (Status, error)
+	Stop(ctx context.Context, at time.Time) (Status, error)
+	// ends the running session at and moves it to entropy, empty reason is stored as NULL
+	Reset(ctx context.Context, at time.Time, reason string) (Status, error)
+}
+
+type causeKey struct{}
+
+// marks the timer calls made with ctx as not coming from the user, like "idle" when a session
+// is split around idle time, so the events can tell. It's sent along to the daemon too
+func WithCause(ctx context.Context, cause string) context.Context {
+	return context.WithValue(ctx, causeKey{}, cause)
+}
+
+// the cause ctx was marked with, empty when the user asked
+func Cause(ctx context.Context) string {
+	cause, _ := ctx.Value(causeKey{}).(string)
+	return cause
+}
+
+// one request per line on the socket, answered by one response line
+type request struct {
+	Op     string    `json:"op"`
+	TaskID int64     `json:"task_id,omitempty"`
+	At     time.Time `json:"at"`
+	Reason string    `json:"reason,omitempty"`
+	Cause  string    `json:"cause,omitempty"`
+}
+
+type response struct {
+	Status Status `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+const (
+	opStatus = "status"
+	opStart  = "start"
+	opStop   = "stop"
+	opReset  = "reset"
+)
+
+// $XDG_RUNTIME_DIR/negentropy.sock, or a per user socket in the temp dir
+func DefaultSocketPath() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "negentropy.sock")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("negentropy-%d.sock", os.Getuid()))
+}
+
+// sentinel errors lose their identity on the wire, this gets them back
+func decodeError(msg string) error {
+	for _, err := range []error{ErrRunning, ErrNotRunning} {
+		if msg == err.Error() {
+			return err
+		}
+	}
+	return errors.New(msg)
+}
@@ -0,0 +1,108 @@
+package daemon
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// listens on the socket at path. A socket nobody answers on is left over from a daemon that
+// crashed and gets replaced, a live one means the daemon is already running
+func Listen(path string) (net.Listener, error) {
+	if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("daemon already running on %s", path)
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	// the socket controls the timer, nobody else gets to use it
+	if err := os.Chmod(path, 0o600); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// answers requests on l with t until ctx is done. Requests from all clients are handled one
+// at a time, so two clients starting a session at once can't both succeed
+func Serve(ctx context.Context, l net.Listener, t Timer) error {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+	defer wg.Wait()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			serveConn(ctx, conn, t, &mu)
+		}()
+	}
+}
+
+func serveConn(ctx context.Context, conn net.Conn, t Timer, mu *sync.Mutex) {
+	defer conn.Close()
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+	enc := json.NewEncoder(conn)
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		var req request
+		var resp response
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			resp.Error = "bad request: " + err.Error()
+		} else {
+			mu.Lock()
+			status, err := handle(ctx, t, req)
+			mu.Unlock()
+			resp.Status = status
+			if err != nil {
+				resp.Error = err.Error()
+			}
+		}
+		if err := enc.Encode(resp); err != nil {
+			log.Printf("daemon: %v", err)
+			return
+		}
+	}
+}
+
+func handle(ctx context.Context, t Timer, req request) (Status, error) {
+	if req.Cause != "" {
+		ctx = WithCause(ctx, req.Cause)
+	}
+	switch req.Op {
+	case opStatus:
+		return t.Status(ctx)
+	case opStart:
+		return t.Start(ctx, req.TaskID, req.At)
+	case opStop:
+		return t.Stop(ctx, req.At)
+	case opReset:
+		return t.Reset(ctx, req.At, req.Reason)
+	}
+	return Status{}, fmt.Errorf("unknown op %q", req.Op)
+}
@@ -0,0 +1,15 @@
+-- name: SetGoal :one
+INSERT INTO goals (task_id, weekly_seconds)
+VALUES (?, ?)
+ON CONFLICT (task_id) DO UPDATE
+SET weekly_seconds = excluded.weekly_seconds
+RETURNING *;
+
+-- name: GetGoals :many
+SELECT *
+FROM goals
+ORDER BY task_id;
+
+-- name: DeleteGoal :exec
+DELETE FROM goals
+WHERE task_id = ?;
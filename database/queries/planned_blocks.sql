@@ -0,0 +1,17 @@
+-- name: UpsertPlannedBlock :one
+-- re-importing the same calendar moves blocks instead of duplicating them
+INSERT INTO planned_blocks (task_id, start_time, end_time, title, source_uid)
+VALUES (?, ?, ?, ?, ?)
+ON CONFLICT (source_uid) DO UPDATE
+SET task_id = excluded.task_id,
+start_time = excluded.start_time,
+end_time = excluded.end_time,
+title = excluded.title
+RETURNING *;
+
+-- name: GetPlannedBlocksInRange :many
+SELECT *
+FROM planned_blocks
+WHERE start_time < sqlc.arg(range_end)
+AND end_time > sqlc.arg(range_start)
+ORDER BY start_time;
@@ -0,0 +1,38 @@
+-- name: GetSyncDevices :many
+SELECT *
+FROM sync_devices
+ORDER BY device;
+
+-- name: UpsertSyncDevice :exec
+INSERT INTO sync_devices (device, clock, read_offset)
+VALUES (?, ?, ?)
+ON CONFLICT (device) DO UPDATE
+SET clock = excluded.clock,
+read_offset = excluded.read_offset;
+
+-- name: GetSyncEntities :many
+SELECT *
+FROM sync_entities
+ORDER BY uid;
+
+-- name: UpsertSyncEntity :exec
+INSERT INTO sync_entities (uid, kind, local_id, data, clock, device, deleted)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (uid) DO UPDATE
+SET local_id = excluded.local_id,
+data = excluded.data,
+clock = excluded.clock,
+device = excluded.device,
+deleted = excluded.deleted;
+
+-- name: QueueSyncOp :exec
+INSERT INTO sync_outbox (device, clock, line)
+VALUES (?, ?, ?);
+
+-- name: GetSyncOutbox :many
+SELECT *
+FROM sync_outbox
+ORDER BY device, clock;
+
+-- name: ClearSyncOutbox :exec
+DELETE FROM sync_outbox;
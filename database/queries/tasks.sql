@@ -16,3 +16,11 @@ WHERE daily_target != "ENTROPY";
 -- name: DeleteTask :exec
 DELETE FROM tasks
 WHERE id = ?;
+
+-- name: UpdateTask :exec
+UPDATE tasks
+SET name = ?,
+color_hex = ?,
+completed = ?,
+daily_target = ?
+WHERE id = ?;
@@ -0,0 +1,38 @@
+-- name: EnqueueWebhook :one
+INSERT INTO webhook_outbox (url, event, payload, next_attempt_at, created_at)
+VALUES (?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetDueWebhooks :many
+-- deliveries that haven't failed for good and are due at now, oldest first
+SELECT *
+FROM webhook_outbox
+WHERE failed_at IS NULL
+AND next_attempt_at <= sqlc.arg(now)
+ORDER BY id
+LIMIT sqlc.arg(max_rows);
+
+-- name: ClaimWebhook :execrows
+-- pushes next_attempt_at past the delivery, another process flushing the outbox skips it then
+UPDATE webhook_outbox
+SET next_attempt_at = sqlc.arg(lease_until)
+WHERE id = sqlc.arg(id)
+AND next_attempt_at = sqlc.arg(next_attempt_at);
+
+-- name: DeleteWebhook :exec
+DELETE FROM webhook_outbox
+WHERE id = ?;
+
+-- name: RetryWebhook :exec
+UPDATE webhook_outbox
+SET attempts = attempts + 1,
+next_attempt_at = ?,
+last_error = ?
+WHERE id = ?;
+
+-- name: FailWebhook :exec
+UPDATE webhook_outbox
+SET attempts = attempts + 1,
+failed_at = ?,
+last_error = ?
+WHERE id = ?;
@@ -0,0 +1,34 @@
+-- +goose Up
+-- every device whose log was read from the sync folder, and this one. Which one is this
+-- device is kept outside the database, see sync.go
+CREATE    TABLE sync_devices (
+          device TEXT PRIMARY KEY,
+          clock INTEGER NOT NULL DEFAULT 0,
+          read_offset INTEGER NOT NULL DEFAULT 0
+          );
+
+-- last synced state of every task and session, keyed by an id that's the same on all devices
+CREATE    TABLE sync_entities (
+          uid TEXT PRIMARY KEY,
+          kind TEXT NOT NULL,
+          local_id INTEGER NOT NULL,
+          data TEXT NOT NULL,
+          clock INTEGER NOT NULL,
+          device TEXT NOT NULL,
+          deleted BOOLEAN NOT NULL DEFAULT FALSE
+          );
+
+-- changes of this device that were committed but might not be in its log yet
+CREATE    TABLE sync_outbox (
+          device TEXT NOT NULL,
+          clock INTEGER NOT NULL,
+          line TEXT NOT NULL,
+          PRIMARY KEY (device, clock)
+          );
+
+-- +goose Down
+DROP      TABLE sync_outbox;
+
+DROP      TABLE sync_entities;
+
+DROP      TABLE sync_devices;
@@ -0,0 +1,8 @@
+-- +goose Up
+-- entropy sessions are moved to task 0, so the task they were taken from is kept here
+ALTER     TABLE sessions ADD COLUMN origin_task_id INTEGER;
+ALTER     TABLE sessions ADD COLUMN entropy_reason TEXT;
+
+-- +goose Down
+ALTER     TABLE sessions DROP COLUMN entropy_reason;
+ALTER     TABLE sessions DROP COLUMN origin_task_id;
@@ -0,0 +1,6 @@
+-- +goose Up
+-- 'work' or 'break', breaks keep the task they interrupted but don't count as tracked time
+ALTER     TABLE sessions ADD COLUMN kind TEXT NOT NULL DEFAULT 'work';
+
+-- +goose Down
+ALTER     TABLE sessions DROP COLUMN kind;
@@ -0,0 +1,10 @@
+-- +goose Up
+CREATE    TABLE goals (
+          id INTEGER PRIMARY KEY AUTOINCREMENT,
+          task_id INTEGER NOT NULL UNIQUE,
+          weekly_seconds INTEGER NOT NULL,
+          FOREIGN KEY (task_id) REFERENCES tasks (id) ON DELETE CASCADE
+          );
+
+-- +goose Down
+DROP      TABLE goals;
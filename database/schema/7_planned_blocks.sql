@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE    TABLE planned_blocks (
+          id INTEGER PRIMARY KEY AUTOINCREMENT,
+          task_id INTEGER NOT NULL,
+          start_time TEXT NOT NULL,
+          end_time TEXT NOT NULL,
+          title TEXT NOT NULL,
+          source_uid TEXT NOT NULL UNIQUE,
+          FOREIGN KEY (task_id) REFERENCES tasks (id) ON DELETE CASCADE
+          );
+
+-- +goose Down
+DROP      TABLE planned_blocks;
@@ -0,0 +1,36 @@
+-- +goose Up
+-- the old ResetSession wrote its end time with the layout 15:04:0, a literal 0 where the
+-- seconds go. They can't be recovered, the time is rounded down to the minute
+UPDATE    sessions
+SET       end_time = end_time || '0'
+WHERE     end_time GLOB '????-??-?? ??:??:?';
+
+-- timestamps were local time without an offset, the 'utc' modifier converts them using the
+-- timezone of the machine running the migration
+UPDATE    sessions
+SET       start_time = strftime('%Y-%m-%dT%H:%M:%SZ', start_time, 'utc')
+WHERE     start_time NOT LIKE '%Z';
+
+UPDATE    sessions
+SET       end_time = strftime('%Y-%m-%dT%H:%M:%SZ', end_time, 'utc')
+WHERE     end_time IS NOT NULL
+AND       end_time NOT LIKE '%Z';
+
+UPDATE    planned_blocks
+SET       start_time = strftime('%Y-%m-%dT%H:%M:%SZ', start_time, 'utc'),
+          end_time = strftime('%Y-%m-%dT%H:%M:%SZ', end_time, 'utc')
+WHERE     start_time NOT LIKE '%Z';
+
+-- +goose Down
+UPDATE    sessions
+SET       start_time = strftime('%Y-%m-%d %H:%M:%S', start_time, 'localtime')
+WHERE     start_time LIKE '%Z';
+
+UPDATE    sessions
+SET       end_time = strftime('%Y-%m-%d %H:%M:%S', end_time, 'localtime')
+WHERE     end_time LIKE '%Z';
+
+UPDATE    planned_blocks
+SET       start_time = strftime('%Y-%m-%d %H:%M:%S', start_time, 'localtime'),
+          end_time = strftime('%Y-%m-%d %H:%M:%S', end_time, 'localtime')
+WHERE     start_time LIKE '%Z';
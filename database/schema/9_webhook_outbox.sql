@@ -0,0 +1,17 @@
+-- +goose Up
+CREATE    TABLE webhook_outbox (
+          id INTEGER PRIMARY KEY AUTOINCREMENT,
+          url TEXT NOT NULL,
+          event TEXT NOT NULL,
+          payload TEXT NOT NULL,
+          attempts INTEGER NOT NULL DEFAULT 0,
+          next_attempt_at TEXT NOT NULL,
+          last_error TEXT,
+          failed_at TEXT,
+          created_at TEXT NOT NULL
+          );
+
+CREATE    INDEX webhook_outbox_due ON webhook_outbox (failed_at, next_attempt_at);
+
+-- +goose Down
+DROP      TABLE webhook_outbox;
@@ -8,11 +8,51 @@ import (
 	"database/sql"
 )
 
+type Goal struct {
+	ID            int64 `json:"id"`
+	TaskID        int64 `json:"task_id"`
+	WeeklySeconds int64 `json:"weekly_seconds"`
+}
+
+type PlannedBlock struct {
+	ID        int64  `json:"id"`
+	TaskID    int64  `json:"task_id"`
+	StartTime string `json:"start_time"`
+	EndTime   string `json:"end_time"`
+	Title     string `json:"title"`
+	SourceUid string `json:"source_uid"`
+}
+
 type Session struct {
-	ID        int64          `json:"id"`
-	StartTime string         `json:"start_time"`
-	EndTime   sql.NullString `json:"end_time"`
-	TaskID    int64          `json:"task_id"`
+	ID            int64          `json:"id"`
+	StartTime     string         `json:"start_time"`
+	EndTime       sql.NullString `json:"end_time"`
+	TaskID        int64          `json:"task_id"`
+	OriginTaskID  sql.NullInt64  `json:"origin_task_id"`
+	EntropyReason sql.NullString `json:"entropy_reason"`
+	Kind          string         `json:"kind"`
+}
+
+type SyncDevice struct {
+	Device     string `json:"device"`
+	Clock      int64  `json:"clock"`
+	ReadOffset int64  `json:"read_offset"`
+}
+
+type SyncEntity struct {
+	Uid     string `json:"uid"`
+	Kind    string `json:"kind"`
+	LocalID int64  `json:"local_id"`
+	Data    string `json:"data"`
+	Clock   int64  `json:"clock"`
+	Device  string `json:"device"`
+	Deleted bool   `json:"deleted"`
+}
+
+type SyncOutbox struct {
+	Device string `json:"device"`
+	Clock  int64  `json:"clock"`
+	Line   string `json:"line"`
 }
 
 type Task struct {
@@ -22,3 +62,15 @@ type Task struct {
 	Completed   sql.NullBool   `json:"completed"`
 	DailyTarget sql.NullInt64  `json:"daily_target"`
 }
+
+type WebhookOutbox struct {
+	ID            int64          `json:"id"`
+	Url           string         `json:"url"`
+	Event         string         `json:"event"`
+	Payload       string         `json:"payload"`
+	Attempts      int64          `json:"attempts"`
+	NextAttemptAt string         `json:"next_attempt_at"`
+	LastError     sql.NullString `json:"last_error"`
+	FailedAt      sql.NullString `json:"failed_at"`
+	CreatedAt     string         `json:"created_at"`
+}
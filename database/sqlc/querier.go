@@ -10,14 +10,45 @@ import (
 )
 
 type Querier interface {
+	// pushes next_attempt_at past the delivery, another process flushing the outbox skips it then
+	ClaimWebhook(ctx context.Context, arg ClaimWebhookParams) (int64, error)
+	ClearSyncOutbox(ctx context.Context) error
+	CountSessionsAt(ctx context.Context, arg CountSessionsAtParams) (int64, error)
 	CreateTask(ctx context.Context, arg CreateTaskParams) (Task, error)
+	DeleteGoal(ctx context.Context, taskID int64) error
+	DeleteSession(ctx context.Context, id int64) error
 	DeleteTask(ctx context.Context, id int64) error
+	DeleteWebhook(ctx context.Context, id int64) error
 	EndSession(ctx context.Context, arg EndSessionParams) (Session, error)
 	EndSessionAsEntropy(ctx context.Context, arg EndSessionAsEntropyParams) (Session, error)
-	GetDailyTaskDurations(ctx context.Context, queryDate string) ([]GetDailyTaskDurationsRow, error)
+	EnqueueWebhook(ctx context.Context, arg EnqueueWebhookParams) (WebhookOutbox, error)
+	FailWebhook(ctx context.Context, arg FailWebhookParams) error
+	// deliveries that haven't failed for good and are due at now, oldest first
+	GetDueWebhooks(ctx context.Context, arg GetDueWebhooksParams) ([]WebhookOutbox, error)
+	GetGoals(ctx context.Context) ([]Goal, error)
 	GetHours(ctx context.Context) (sql.NullFloat64, error)
+	GetPlannedBlocksInRange(ctx context.Context, arg GetPlannedBlocksInRangeParams) ([]PlannedBlock, error)
+	// the session without an end, there's at most one unless the db was edited by hand
+	GetRunningSession(ctx context.Context) (Session, error)
+	// every session overlapping [range_start, range_end), including the running one
+	GetSessionsInRange(ctx context.Context, arg GetSessionsInRangeParams) ([]Session, error)
+	GetSyncDevices(ctx context.Context) ([]SyncDevice, error)
+	GetSyncEntities(ctx context.Context) ([]SyncEntity, error)
+	GetSyncOutbox(ctx context.Context) ([]SyncOutbox, error)
 	GetTasks(ctx context.Context) ([]Task, error)
+	// for sessions that are already finished, like idle spans split out of a running session
+	InsertSession(ctx context.Context, arg InsertSessionParams) (Session, error)
+	QueueSyncOp(ctx context.Context, arg QueueSyncOpParams) error
+	RetryWebhook(ctx context.Context, arg RetryWebhookParams) error
+	SetGoal(ctx context.Context, arg SetGoalParams) (Goal, error)
+	// inserts nothing while another session is running, checked and inserted in one statement
 	StartSession(ctx context.Context, arg StartSessionParams) (Session, error)
+	UpdateSession(ctx context.Context, arg UpdateSessionParams) error
+	UpdateTask(ctx context.Context, arg UpdateTaskParams) error
+	// re-importing the same calendar moves blocks instead of duplicating them
+	UpsertPlannedBlock(ctx context.Context, arg UpsertPlannedBlockParams) (PlannedBlock, error)
+	UpsertSyncDevice(ctx context.Context, arg UpsertSyncDeviceParams) error
+	UpsertSyncEntity(ctx context.Context, arg UpsertSyncEntityParams) error
 }
 
 var _ Querier = (*Queries)(nil)
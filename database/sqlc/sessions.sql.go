@@ -10,11 +10,41 @@ import (
 	"database/sql"
 )
 
+const countSessionsAt = `-- name: CountSessionsAt :one
+SELECT COUNT(*)
+FROM sessions
+WHERE task_id = ?
+AND start_time = ?
+`
+
+type CountSessionsAtParams struct {
+	TaskID    int64  `json:"task_id"`
+	StartTime string `json:"start_time"`
+}
+
+func (q *Queries) CountSessionsAt(ctx context.Context, arg CountSessionsAtParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countSessionsAt, arg.TaskID, arg.StartTime)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const deleteSession = `-- name: DeleteSession :exec
+DELETE FROM sessions
+WHERE id = ?
+`
+
+func (q *Queries) DeleteSession(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, deleteSession, id)
+	return err
+}
+
 const endSession = `-- name: EndSession :one
 UPDATE sessions
 SET end_time = ?
 WHERE task_id = ?
-RETURNING id, start_time, end_time, task_id
+AND end_time IS NULL
+RETURNING id, start_time, end_time, task_id, origin_task_id, entropy_reason, kind
 `
 
 type EndSessionParams struct {
@@ -30,6 +60,9 @@ func (q *Queries) EndSession(ctx context.Context, arg EndSessionParams) (Session
 		&i.StartTime,
 		&i.EndTime,
 		&i.TaskID,
+		&i.OriginTaskID,
+		&i.EntropyReason,
+		&i.Kind,
 	)
 	return i, err
 }
@@ -38,76 +71,92 @@ const endSessionAsEntropy = `-- name: EndSessionAsEntropy :one
 UPDATE sessions
 SET
 end_time = ?,
+entropy_reason = ?,
+origin_task_id = task_id,
 task_id = 0
 
 WHERE task_id = ?
-RETURNING id, start_time, end_time, task_id
+AND end_time IS NULL
+RETURNING id, start_time, end_time, task_id, origin_task_id, entropy_reason, kind
 `
 
 type EndSessionAsEntropyParams struct {
-	EndTime sql.NullString `json:"end_time"`
-	TaskID  int64          `json:"task_id"`
+	EndTime       sql.NullString `json:"end_time"`
+	EntropyReason sql.NullString `json:"entropy_reason"`
+	TaskID        int64          `json:"task_id"`
 }
 
 func (q *Queries) EndSessionAsEntropy(ctx context.Context, arg EndSessionAsEntropyParams) (Session, error) {
-	row := q.db.QueryRowContext(ctx, endSessionAsEntropy, arg.EndTime, arg.TaskID)
+	row := q.db.QueryRowContext(ctx, endSessionAsEntropy, arg.EndTime, arg.EntropyReason, arg.TaskID)
 	var i Session
 	err := row.Scan(
 		&i.ID,
 		&i.StartTime,
 		&i.EndTime,
 		&i.TaskID,
+		&i.OriginTaskID,
+		&i.EntropyReason,
+		&i.Kind,
 	)
 	return i, err
 }
 
-const getDailyTaskDurations = `-- name: GetDailyTaskDurations :many
-SELECT task_id, SUM(duration_seconds) as total_seconds
-FROM (
-    -- the session that spans two days will be stored in db as a single session, but will be divided between 2 days inside the app
-    -- also this is for anyday, not just today. will make wrappers for this query inside app instead.
-    -- '?' arg is the queried date
-    -- 1. sessions spanning single day, day x
-    SELECT s.task_id,
-    strftime('%s', s.end_time) - strftime('%s', s.start_time) AS duration_seconds
-    FROM sessions AS s
-    WHERE s.start_time >= ?1
-    AND s.end_time < strftime('%s', ?1, '+1 day', 'start of day')
-    UNION ALL
-    -- 2. sessions spanning two days, started on day x (not accounting for sessions spanning more than 2 days)
-    SELECT s.task_id,
-    strftime('%s', ?1, '+1 day', 'start of day') - strftime('%s', start_time) AS duration_seconds
-    FROM sessions AS s
-    WHERE date(s.start_time) = date(?1)
-    AND date(s.end_time) = date(?1, '+1 day')
-    OR s.end_time IS NULL
-    UNION ALL
-
-    --3. sessions spanning two days, ending on day x
-    SELECT s.task_id,
-    strftime('%s', s.end_time) - strftime('%s', ?1, 'start of day') AS duration_seconds
-    FROM sessions AS s
-    WHERE date(s.start_time) = date(?1, '-1 day')
-    AND date(s.end_time) = date(?1)
-) AS daily_sessions -- https://github.com/sqlc-dev/sqlc/issues/3963
-GROUP BY task_id
+const getRunningSession = `-- name: GetRunningSession :one
+SELECT id, start_time, end_time, task_id, origin_task_id, entropy_reason, kind
+FROM sessions
+WHERE end_time IS NULL
+ORDER BY start_time DESC
+LIMIT 1
 `
 
-type GetDailyTaskDurationsRow struct {
-	TaskID       int64           `json:"task_id"`
-	TotalSeconds sql.NullFloat64 `json:"total_seconds"`
+// the session without an end, there's at most one unless the db was edited by hand
+func (q *Queries) GetRunningSession(ctx context.Context) (Session, error) {
+	row := q.db.QueryRowContext(ctx, getRunningSession)
+	var i Session
+	err := row.Scan(
+		&i.ID,
+		&i.StartTime,
+		&i.EndTime,
+		&i.TaskID,
+		&i.OriginTaskID,
+		&i.EntropyReason,
+		&i.Kind,
+	)
+	return i, err
 }
 
-func (q *Queries) GetDailyTaskDurations(ctx context.Context, queryDate string) ([]GetDailyTaskDurationsRow, error) {
-	rows, err := q.db.QueryContext(ctx, getDailyTaskDurations, queryDate)
+const getSessionsInRange = `-- name: GetSessionsInRange :many
+SELECT id, start_time, end_time, task_id, origin_task_id, entropy_reason, kind
+FROM sessions
+WHERE start_time < ?1
+AND (end_time IS NULL OR end_time > ?2)
+ORDER BY start_time
+`
+
+type GetSessionsInRangeParams struct {
+	RangeEnd   string `json:"range_end"`
+	RangeStart string `json:"range_start"`
+}
+
+// every session overlapping [range_start, range_end), including the running one
+func (q *Queries) GetSessionsInRange(ctx context.Context, arg GetSessionsInRangeParams) ([]Session, error) {
+	rows, err := q.db.QueryContext(ctx, getSessionsInRange, arg.RangeEnd, arg.RangeStart)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []GetDailyTaskDurationsRow
+	var items []Session
 	for rows.Next() {
-		var i GetDailyTaskDurationsRow
-		if err := rows.Scan(&i.TaskID, &i.TotalSeconds); err != nil {
+		var i Session
+		if err := rows.Scan(
+			&i.ID,
+			&i.StartTime,
+			&i.EndTime,
+			&i.TaskID,
+			&i.OriginTaskID,
+			&i.EntropyReason,
+			&i.Kind,
+		); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -121,10 +170,53 @@ func (q *Queries) GetDailyTaskDurations(ctx context.Context, queryDate string) (
 	return items, nil
 }
 
+const insertSession = `-- name: InsertSession :one
+INSERT INTO sessions (start_time, end_time, task_id, origin_task_id, entropy_reason, kind)
+VALUES (?, ?, ?, ?, ?, ?)
+RETURNING id, start_time, end_time, task_id, origin_task_id, entropy_reason, kind
+`
+
+type InsertSessionParams struct {
+	StartTime     string         `json:"start_time"`
+	EndTime       sql.NullString `json:"end_time"`
+	TaskID        int64          `json:"task_id"`
+	OriginTaskID  sql.NullInt64  `json:"origin_task_id"`
+	EntropyReason sql.NullString `json:"entropy_reason"`
+	Kind          string         `json:"kind"`
+}
+
+// for sessions that are already finished, like idle spans split out of a running session
+func (q *Queries) InsertSession(ctx context.Context, arg InsertSessionParams) (Session, error) {
+	row := q.db.QueryRowContext(ctx, insertSession,
+		arg.StartTime,
+		arg.EndTime,
+		arg.TaskID,
+		arg.OriginTaskID,
+		arg.EntropyReason,
+		arg.Kind,
+	)
+	var i Session
+	err := row.Scan(
+		&i.ID,
+		&i.StartTime,
+		&i.EndTime,
+		&i.TaskID,
+		&i.OriginTaskID,
+		&i.EntropyReason,
+		&i.Kind,
+	)
+	return i, err
+}
+
 const startSession = `-- name: StartSession :one
 INSERT INTO sessions (start_time, task_id)
-VALUES (?, ?)
-RETURNING id, start_time, end_time, task_id
+SELECT ?, ?
+WHERE NOT EXISTS (
+    SELECT 1
+    FROM sessions
+    WHERE end_time IS NULL
+    )
+RETURNING id, start_time, end_time, task_id, origin_task_id, entropy_reason, kind
 `
 
 type StartSessionParams struct {
@@ -132,6 +224,7 @@ type StartSessionParams struct {
 	TaskID    int64  `json:"task_id"`
 }
 
+// inserts nothing while another session is running, checked and inserted in one statement
 func (q *Queries) StartSession(ctx context.Context, arg StartSessionParams) (Session, error) {
 	row := q.db.QueryRowContext(ctx, startSession, arg.StartTime, arg.TaskID)
 	var i Session
@@ -140,6 +233,43 @@ func (q *Queries) StartSession(ctx context.Context, arg StartSessionParams) (Ses
 		&i.StartTime,
 		&i.EndTime,
 		&i.TaskID,
+		&i.OriginTaskID,
+		&i.EntropyReason,
+		&i.Kind,
 	)
 	return i, err
 }
+
+const updateSession = `-- name: UpdateSession :exec
+UPDATE sessions
+SET start_time = ?,
+end_time = ?,
+task_id = ?,
+origin_task_id = ?,
+entropy_reason = ?,
+kind = ?
+WHERE id = ?
+`
+
+type UpdateSessionParams struct {
+	StartTime     string         `json:"start_time"`
+	EndTime       sql.NullString `json:"end_time"`
+	TaskID        int64          `json:"task_id"`
+	OriginTaskID  sql.NullInt64  `json:"origin_task_id"`
+	EntropyReason sql.NullString `json:"entropy_reason"`
+	Kind          string         `json:"kind"`
+	ID            int64          `json:"id"`
+}
+
+func (q *Queries) UpdateSession(ctx context.Context, arg UpdateSessionParams) error {
+	_, err := q.db.ExecContext(ctx, updateSession,
+		arg.StartTime,
+		arg.EndTime,
+		arg.TaskID,
+		arg.OriginTaskID,
+		arg.EntropyReason,
+		arg.Kind,
+		arg.ID,
+	)
+	return err
+}
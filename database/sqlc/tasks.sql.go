@@ -92,3 +92,31 @@ func (q *Queries) GetTasks(ctx context.Context) ([]Task, error) {
 	}
 	return items, nil
 }
+
+const updateTask = `-- name: UpdateTask :exec
+UPDATE tasks
+SET name = ?,
+color_hex = ?,
+completed = ?,
+daily_target = ?
+WHERE id = ?
+`
+
+type UpdateTaskParams struct {
+	Name        string         `json:"name"`
+	ColorHex    sql.NullString `json:"color_hex"`
+	Completed   sql.NullBool   `json:"completed"`
+	DailyTarget sql.NullInt64  `json:"daily_target"`
+	ID          int64          `json:"id"`
+}
+
+func (q *Queries) UpdateTask(ctx context.Context, arg UpdateTaskParams) error {
+	_, err := q.db.ExecContext(ctx, updateTask,
+		arg.Name,
+		arg.ColorHex,
+		arg.Completed,
+		arg.DailyTarget,
+		arg.ID,
+	)
+	return err
+}
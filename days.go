@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/chee-zer/negentropy/aggregate"
+)
+
+// format used for every timestamp in the database, always UTC so it sorts and compares as text
+const timestampLayout = "2006-01-02T15:04:05Z"
+
+// local time without an offset, how timestamps were stored before 8_utc_timestamps.sql
+const legacyTimestampLayout = "2006-01-02 15:04:05"
+
+// format used for dates passed on the command line
+const dateLayout = "2006-01-02"
+
+// zone used for day boundaries and for showing times, set from the timezone config at startup
+var dayLocation = time.Local
+
+// offset from midnight where a day starts, work before it counts toward the previous day
+var dayStart time.Duration
+
+func formatTimestamp(t time.Time) string {
+	return t.UTC().Format(timestampLayout)
+}
+
+// parses a database timestamp into dayLocation. Rows that weren't migrated yet are read as
+// local time, including the ones ResetSession wrote with a broken "15:04:0" layout (no seconds)
+func parseTimestamp(s string) (time.Time, error) {
+	t, err := time.Parse(timestampLayout, s)
+	if err == nil {
+		return t.In(dayLocation), nil
+	}
+	for _, layout := range []string{legacyTimestampLayout, "2006-01-02 15:04:0"} {
+		if legacy, lerr := time.ParseInLocation(layout, s, time.Local); lerr == nil {
+			return legacy.In(dayLocation), nil
+		}
+	}
+	return time.Time{}, err
+}
+
+// buckets for reports, following the timezone and day_start config
+func calendar() aggregate.Calendar {
+	return aggregate.Calendar{Location: dayLocation, DayStart: dayStart}
+}
+
+func startOfDay(t time.Time) time.Time {
+	return calendar().Start(t, aggregate.Day)
+}
+
+// start of the given calendar date
+func dayAt(y int, m time.Month, d int) time.Time {
+	return calendar().Date(y, m, d)
+}
+
+// parses the day_start config value, empty means midnight
+func parseDayStart(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid day_start %q, expected HH:MM", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// weeks start on monday
+func startOfWeek(t time.Time) time.Time {
+	return calendar().Start(t, aggregate.Week)
+}
+
+// parses a YYYY-MM-DD flag value, empty string returns the fallback
+func parseDate(s string, fallback time.Time) (time.Time, error) {
+	if s == "" {
+		return fallback, nil
+	}
+	t, err := time.Parse(dateLayout, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", s)
+	}
+	return dayAt(t.Date()), nil
+}
+
+// short human duration: 2h05m, 13m, 40s
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	switch {
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh%02dm", int(d.Hours()), int(d.Minutes())%60)
+	case d >= time.Minute:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	default:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	}
+}
+
+// horizontal bar for text reports, frac is clamped to [0, 1]
+func bar(frac float64, width int) string {
+	if frac < 0 {
+		frac = 0
+	}
+	if frac > 1 {
+		frac = 1
+	}
+	n := int(frac*float64(width) + 0.5)
+	return strings.Repeat("█", n) + strings.Repeat(" ", width-n)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	db "github.com/chee-zer/negentropy/database/sqlc"
+)
+
+// an in-memory database with every migration applied
+func openTestDB(t *testing.T) (*sql.DB, *db.Queries) {
+	t.Helper()
+	sqldb := openTestDBAt(t, ":memory:", -1)
+	return sqldb, db.New(sqldb)
+}
+
+// a database at path migrated up to version, -1 for all of them. The goose_db_version table
+// is filled in like goose does
+func openTestDBAt(t *testing.T, path string, version int) *sql.DB {
+	t.Helper()
+	sqldb, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// every connection to :memory: is a database of its own
+	sqldb.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqldb.Close() })
+
+	files, err := filepath.Glob("database/schema/*.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	versionOf := func(f string) int {
+		n, _, _ := strings.Cut(filepath.Base(f), "_")
+		v, err := strconv.Atoi(n)
+		if err != nil {
+			t.Fatalf("migration %s: %v", f, err)
+		}
+		return v
+	}
+	slices.SortFunc(files, func(a, b string) int { return versionOf(a) - versionOf(b) })
+
+	if _, err := sqldb.Exec(`CREATE TABLE goose_db_version (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		version_id INTEGER NOT NULL,
+		is_applied INTEGER NOT NULL,
+		tstamp TIMESTAMP DEFAULT (datetime('now')))`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sqldb.Exec("INSERT INTO goose_db_version (version_id, is_applied) VALUES (0, 1)"); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		v := versionOf(f)
+		if version >= 0 && v > version {
+			break
+		}
+		b, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, up, _ := strings.Cut(string(b), "-- +goose Up")
+		up, _, _ = strings.Cut(up, "-- +goose Down")
+		if _, err := sqldb.Exec(up); err != nil {
+			t.Fatalf("migration %s: %v", f, err)
+		}
+		if _, err := sqldb.Exec("INSERT INTO goose_db_version (version_id, is_applied) VALUES (?, 1)", v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return sqldb
+}
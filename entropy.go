@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/chee-zer/negentropy/aggregate"
+	db "github.com/chee-zer/negentropy/database/sqlc"
+)
+
+// quick pick offered when a session is reset, anything else is typed in as a custom reason
+var entropyReasons = []string{"distraction", "interruption", "context switch"}
+
+// where and when time was lost to entropy over a date range
+type entropyReport struct {
+	From       time.Time
+	To         time.Time
+	Entropy    time.Duration
+	Productive time.Duration
+	ByHour     [24]time.Duration
+	ByWeekday  [7]time.Duration // indexed by time.Weekday
+	PerTask    []taskEntropy
+	Reasons    []reasonEntropy
+	// days in a row with tracked work and no entropy
+	LongestStreak int
+	CurrentStreak int
+}
+
+type taskEntropy struct {
+	TaskID     int64
+	Name       string
+	Entropy    time.Duration
+	Productive time.Duration
+}
+
+// entropy per productive second, 0 if nothing productive was tracked
+func (t taskEntropy) ratio() float64 {
+	if t.Productive == 0 {
+		return 0
+	}
+	return t.Entropy.Seconds() / t.Productive.Seconds()
+}
+
+type reasonEntropy struct {
+	Reason string
+	Count  int
+	Total  time.Duration
+}
+
+// spans must already be clipped to [from, to)
+func buildEntropyReport(spans []span, tasks map[int64]db.Task, from, to time.Time) entropyReport {
+	r := entropyReport{From: from, To: to}
+	perTask := make(map[int64]*taskEntropy)
+	taskFor := func(id int64) *taskEntropy {
+		if t, ok := perTask[id]; ok {
+			return t
+		}
+		name := fmt.Sprintf("#%d (deleted)", id)
+		if id == entropyTaskID {
+			name = "unknown"
+		} else if t, ok := tasks[id]; ok {
+			name = t.Name
+		}
+		perTask[id] = &taskEntropy{TaskID: id, Name: name}
+		return perTask[id]
+	}
+	reasons := make(map[string]*reasonEntropy)
+	workDays := make(map[time.Time]bool)
+	entropyDays := make(map[time.Time]bool)
+
+	for _, s := range spans {
+		if s.isBreak() {
+			continue
+		}
+		d := s.duration()
+		if !s.isEntropy() {
+			r.Productive += d
+			taskFor(s.TaskID).Productive += d
+			markDays(workDays, s)
+			continue
+		}
+		r.Entropy += d
+		// sessions reset before origin_task_id existed land on task 0, shown as "unknown"
+		taskFor(s.OriginTaskID).Entropy += d
+		markDays(entropyDays, s)
+
+		reason := s.Reason
+		if reason == "" {
+			reason = "none"
+		}
+		if _, ok := reasons[reason]; !ok {
+			reasons[reason] = &reasonEntropy{Reason: reason}
+		}
+		reasons[reason].Count++
+		reasons[reason].Total += d
+
+		// split across hour buckets so a long reset isn't dumped into its start hour
+		for _, h := range calendar().Split(s.interval(), aggregate.Hour, s.End) {
+			r.ByHour[h.Start.Hour()] += h.End.Sub(h.Start)
+			r.ByWeekday[startOfDay(h.Start).Weekday()] += h.End.Sub(h.Start)
+		}
+	}
+
+	for _, t := range perTask {
+		r.PerTask = append(r.PerTask, *t)
+	}
+	sort.Slice(r.PerTask, func(i, j int) bool {
+		a, b := r.PerTask[i], r.PerTask[j]
+		if a.ratio() != b.ratio() {
+			return a.ratio() > b.ratio()
+		}
+		if a.Entropy != b.Entropy {
+			return a.Entropy > b.Entropy
+		}
+		return a.Productive > b.Productive
+	})
+	for _, v := range reasons {
+		r.Reasons = append(r.Reasons, *v)
+	}
+	sort.Slice(r.Reasons, func(i, j int) bool {
+		return r.Reasons[i].Total > r.Reasons[j].Total
+	})
+
+	streak := 0
+	for day := startOfDay(from); day.Before(to); day = day.AddDate(0, 0, 1) {
+		if workDays[day] && !entropyDays[day] {
+			streak++
+		} else {
+			streak = 0
+		}
+		r.LongestStreak = max(r.LongestStreak, streak)
+	}
+	r.CurrentStreak = streak
+	return r
+}
+
+// marks every day the span touches
+func markDays(days map[time.Time]bool, s span) {
+	for _, d := range calendar().Split(s.interval(), aggregate.Day, s.End) {
+		days[startOfDay(d.Start)] = true
+	}
+}
+
+func (r entropyReport) Render(w io.Writer) {
+	const barWidth = 30
+	fmt.Fprintf(w, "Entropy report %s → %s\n\n", r.From.Format(dateLayout), r.To.AddDate(0, 0, -1).Format(dateLayout))
+	fmt.Fprintf(w, "  productive: %s   entropy: %s", formatDuration(r.Productive), formatDuration(r.Entropy))
+	if r.Productive > 0 {
+		fmt.Fprintf(w, "   (%.0f%% of productive time)", 100*r.Entropy.Seconds()/r.Productive.Seconds())
+	}
+	fmt.Fprintf(w, "\n  days without entropy: current %d, longest %d\n", r.CurrentStreak, r.LongestStreak)
+	if r.Entropy == 0 {
+		fmt.Fprintln(w, "\nNo entropy in this range :)")
+		return
+	}
+
+	var peak time.Duration
+	for _, d := range r.ByHour {
+		peak = max(peak, d)
+	}
+	fmt.Fprintln(w, "\nBy hour of day")
+	for h, d := range r.ByHour {
+		fmt.Fprintf(w, "  %02d:00 %s %s\n", h, bar(d.Seconds()/peak.Seconds(), barWidth), formatDuration(d))
+	}
+
+	peak = 0
+	for _, d := range r.ByWeekday {
+		peak = max(peak, d)
+	}
+	fmt.Fprintln(w, "\nBy weekday")
+	// monday first
+	for i := 1; i <= 7; i++ {
+		wd := time.Weekday(i % 7)
+		d := r.ByWeekday[wd]
+		fmt.Fprintf(w, "  %-3s %s %s\n", wd.String()[:3], bar(d.Seconds()/peak.Seconds(), barWidth), formatDuration(d))
+	}
+
+	fmt.Fprintln(w, "\nEntropy per productive time, by task")
+	for _, t := range r.PerTask {
+		fmt.Fprintf(w, "  %-20s %8s / %-8s %5.2f\n", t.Name, formatDuration(t.Entropy), formatDuration(t.Productive), t.ratio())
+	}
+
+	fmt.Fprintln(w, "\nReasons")
+	for _, re := range r.Reasons {
+		fmt.Fprintf(w, "  %-20s %3dx %s\n", re.Reason, re.Count, formatDuration(re.Total))
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/chee-zer/negentropy/daemon"
+	db "github.com/chee-zer/negentropy/database/sqlc"
+)
+
+// names of the events, also the keys of the hooks config
+const (
+	eventSessionStart   = "session_start"
+	eventSessionStop    = "session_stop"
+	eventSessionEntropy = "session_entropy"
+	eventTaskCreate     = "task_create"
+	eventTaskDelete     = "task_delete"
+	eventTargetReached  = "target_reached"
+)
+
+// event causes, see daemon.WithCause
+const (
+	// the session was split around idle time, the user didn't stop or start it
+	causeIdle = "idle"
+)
+
+var eventNames = []string{eventSessionStart, eventSessionStop, eventSessionEntropy, eventTaskCreate, eventTaskDelete, eventTargetReached}
+
+// something that happened, as handed to hooks and other subscribers
+type event struct {
+	Name    string        `json:"event"`
+	Time    time.Time     `json:"time"`
+	Task    *eventTask    `json:"task,omitempty"`
+	Session *eventSession `json:"session,omitempty"`
+	// why it happened when the user didn't ask for it, empty otherwise
+	Cause string `json:"cause,omitempty"`
+}
+
+type eventTask struct {
+	ID                 int64  `json:"id"`
+	Name               string `json:"name"`
+	DailyTargetSeconds int64  `json:"daily_target_seconds,omitempty"`
+}
+
+type eventSession struct {
+	ID              int64      `json:"id"`
+	TaskID          int64      `json:"task_id"`
+	Start           time.Time  `json:"start"`
+	End             *time.Time `json:"end,omitempty"`
+	DurationSeconds int64      `json:"duration_seconds,omitempty"`
+	EntropyReason   string     `json:"entropy_reason,omitempty"`
+}
+
+func taskEvent(name string, t db.Task, at time.Time) event {
+	return event{Name: name, Time: at.Truncate(time.Second), Task: &eventTask{ID: t.ID, Name: t.Name, DailyTargetSeconds: t.DailyTarget.Int64}}
+}
+
+// task_create for tasks made in a transaction, like the ones import and sync create. Called
+// once it's committed, so a task that was rolled back is never announced
+func emitTaskCreates(ctx context.Context, q db.Querier, d *dispatcher, ids []int64, at time.Time) {
+	if len(ids) == 0 {
+		return
+	}
+	tasks, err := q.GetTasks(ctx)
+	if err != nil {
+		log.Printf("event %s: %v", eventTaskCreate, err)
+		return
+	}
+	for _, t := range tasks {
+		if slices.Contains(ids, t.ID) {
+			d.emit(taskEvent(eventTaskCreate, t, at))
+		}
+	}
+}
+
+// event for a session that started or ended at at, task is looked up for its name
+func sessionEvent(ctx context.Context, q db.Querier, name string, s db.Session, at time.Time) event {
+	// the database keeps whole seconds, the event matches it
+	at = at.Truncate(time.Second)
+	ev := event{Name: name, Time: at, Cause: daemon.Cause(ctx)}
+	start, err := parseTimestamp(s.StartTime)
+	if err != nil {
+		log.Printf("event %s: %v", name, err)
+	}
+	ev.Session = &eventSession{ID: s.ID, TaskID: s.TaskID, Start: start, EntropyReason: s.EntropyReason.String}
+	if name != eventSessionStart {
+		ev.Session.End = &at
+		ev.Session.DurationSeconds = int64(at.Sub(start).Seconds())
+	}
+	// entropy sessions are filed under task 0, the event is about the task they came from
+	taskID := s.TaskID
+	if name == eventSessionEntropy {
+		taskID = s.OriginTaskID.Int64
+		ev.Session.TaskID = taskID
+	}
+	tasks, err := q.GetTasks(ctx)
+	if err != nil {
+		log.Printf("event %s: %v", name, err)
+	}
+	for _, t := range tasks {
+		if t.ID == taskID {
+			ev.Task = &eventTask{ID: t.ID, Name: t.Name, DailyTargetSeconds: t.DailyTarget.Int64}
+		}
+	}
+	return ev
+}
+
+// receives every event. handle must not block, slow work goes to a goroutine tracked by wait
+type subscriber interface {
+	handle(ev event)
+	wait()
+}
+
+// hands events to every subscriber. The zero value drops everything
+type dispatcher struct {
+	mu   sync.Mutex
+	subs []subscriber
+}
+
+func (d *dispatcher) subscribe(s subscriber) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.subs = append(d.subs, s)
+}
+
+func (d *dispatcher) emit(ev event) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, s := range d.subs {
+		s.handle(ev)
+	}
+}
+
+// waits for subscribers to finish what they started, called before the process exits
+func (d *dispatcher) wait() {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	subs := d.subs
+	d.mu.Unlock()
+	for _, s := range subs {
+		s.wait()
+	}
+}
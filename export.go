@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	db "github.com/chee-zer/negentropy/database/sqlc"
+)
+
+// a session joined with its task, keeps the json names of db.Session
+type exportRow struct {
+	ID              int64   `json:"id"`
+	TaskID          int64   `json:"task_id"`
+	TaskName        string  `json:"task_name"`
+	StartTime       string  `json:"start_time"`
+	EndTime         *string `json:"end_time"`
+	DurationSeconds int64   `json:"duration_seconds"`
+	Running         bool    `json:"running"`
+	Kind            string  `json:"kind"`
+	Entropy         bool    `json:"entropy"`
+	OriginTaskID    *int64  `json:"origin_task_id"`
+	OriginTaskName  *string `json:"origin_task_name"`
+	EntropyReason   *string `json:"entropy_reason"`
+}
+
+var exportColumns = []string{
+	"id", "task_id", "task_name", "start_time", "end_time", "duration_seconds", "running",
+	"kind", "entropy", "origin_task_id", "origin_task_name", "entropy_reason",
+}
+
+func taskName(tasks map[int64]db.Task, id int64) string {
+	if t, ok := tasks[id]; ok {
+		return t.Name
+	}
+	return fmt.Sprintf("#%d", id)
+}
+
+// whole sessions, not clipped to the exported range. Times are RFC 3339 with the local offset
+func toExportRows(sessions []db.Session, tasks map[int64]db.Task, now time.Time) ([]exportRow, error) {
+	rows := make([]exportRow, 0, len(sessions))
+	for _, s := range sessions {
+		sp, err := spanFromSession(s, now)
+		if err != nil {
+			return nil, err
+		}
+		r := exportRow{
+			ID:              s.ID,
+			TaskID:          s.TaskID,
+			TaskName:        taskName(tasks, s.TaskID),
+			StartTime:       sp.Start.Format(time.RFC3339),
+			DurationSeconds: int64(sp.duration().Seconds()),
+			Running:         sp.Running,
+			Kind:            s.Kind,
+			Entropy:         sp.isEntropy(),
+		}
+		if !sp.Running {
+			end := sp.End.Format(time.RFC3339)
+			r.EndTime = &end
+		}
+		if s.OriginTaskID.Valid {
+			id, name := s.OriginTaskID.Int64, taskName(tasks, s.OriginTaskID.Int64)
+			r.OriginTaskID, r.OriginTaskName = &id, &name
+		}
+		if s.EntropyReason.Valid {
+			r.EntropyReason = &s.EntropyReason.String
+		}
+		rows = append(rows, r)
+	}
+	return rows, nil
+}
+
+func writeExport(w io.Writer, rows []exportRow, format string) error {
+	switch format {
+	case "csv":
+		return writeCSV(w, rows)
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rows)
+	case "ndjson":
+		enc := json.NewEncoder(w)
+		for _, r := range rows {
+			if err := enc.Encode(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown format %q, use csv, json or ndjson", format)
+}
+
+func writeCSV(w io.Writer, rows []exportRow) error {
+	cw := csv.NewWriter(w)
+	cw.Write(exportColumns)
+	str := func(s *string) string {
+		if s == nil {
+			return ""
+		}
+		return *s
+	}
+	for _, r := range rows {
+		origin := ""
+		if r.OriginTaskID != nil {
+			origin = strconv.FormatInt(*r.OriginTaskID, 10)
+		}
+		cw.Write([]string{
+			strconv.FormatInt(r.ID, 10),
+			strconv.FormatInt(r.TaskID, 10),
+			r.TaskName,
+			r.StartTime,
+			str(r.EndTime),
+			strconv.FormatInt(r.DurationSeconds, 10),
+			strconv.FormatBool(r.Running),
+			r.Kind,
+			strconv.FormatBool(r.Entropy),
+			origin,
+			str(r.OriginTaskName),
+			str(r.EntropyReason),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
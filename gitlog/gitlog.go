@@ -0,0 +1,153 @@
+package gitlog
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// one commit as read from `git log`
+type Commit struct {
+	Repo    string // path of the repository it was read from
+	Hash    string
+	Time    time.Time // author date, rebases and amends keep it
+	Author  string    // email
+	Subject string
+}
+
+func (c Commit) ShortHash() string {
+	return c.Hash[:min(7, len(c.Hash))]
+}
+
+// last element of the repository path, for lists
+func (c Commit) RepoName() string {
+	return filepath.Base(c.Repo)
+}
+
+// fields split by the unit separator, it never shows up in subjects
+const format = "%H%x1f%at%x1f%ae%x1f%s"
+
+// commits on any branch authored by author (an email, any case, empty for everyone) in
+// [since, until), newest first
+func Log(ctx context.Context, repo, author string, since, until time.Time) ([]Commit, error) {
+	args := []string{"-C", repo, "log", "--all", "--no-merges", "--format=" + format,
+		// --since goes by committer date, which is never before the author date
+		"--since=" + since.Format(time.RFC3339)}
+	if author != "" {
+		// --author is a regex matched anywhere in "name <email>", the exact check is below
+		args = append(args, "--extended-regexp", "--regexp-ignore-case", "--author=<"+regexp.QuoteMeta(author)+">")
+	}
+	out, err := run(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+	commits, err := Parse(bytes.NewReader(out), repo)
+	if err != nil {
+		return nil, err
+	}
+	kept := commits[:0]
+	for _, c := range commits {
+		if !c.Time.Before(since) && c.Time.Before(until) && (author == "" || strings.EqualFold(c.Author, author)) {
+			kept = append(kept, c)
+		}
+	}
+	return kept, nil
+}
+
+// user.email as configured for repo, what Log's author usually is. Empty if none is set
+func Email(ctx context.Context, repo string) (string, error) {
+	out, err := run(ctx, "-C", repo, "config", "user.email")
+	var exit *exec.ExitError
+	if errors.As(err, &exit) && exit.ExitCode() == 1 {
+		// not set
+		return "", nil
+	}
+	return strings.TrimSpace(string(out)), err
+}
+
+// urls of every remote of the repository dir is in, normalized with NormalizeRemote
+func Remotes(ctx context.Context, dir string) ([]string, error) {
+	out, err := run(ctx, "-C", dir, "config", "--get-regexp", `^remote\..*\.url$`)
+	var exit *exec.ExitError
+	if errors.As(err, &exit) && exit.ExitCode() == 1 {
+		// no remotes
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var urls []string
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if _, url, ok := strings.Cut(line, " "); ok {
+			urls = append(urls, NormalizeRemote(url))
+		}
+	}
+	return urls, nil
+}
+
+// host/path of a remote url without the scheme, user, port and .git, lowercased, so the ssh
+// and https urls of a repository come out the same: github.com/chee-zer/negentropy
+func NormalizeRemote(url string) string {
+	u := strings.TrimSpace(url)
+	if _, rest, ok := strings.Cut(u, "://"); ok {
+		u = rest
+	} else if host, p, ok := strings.Cut(u, ":"); ok && !strings.Contains(host, "/") {
+		// scp like git@github.com:chee-zer/negentropy.git
+		u = host + "/" + p
+	}
+	host, p, _ := strings.Cut(u, "/")
+	if _, h, ok := strings.Cut(host, "@"); ok {
+		host = h
+	}
+	host, _, _ = strings.Cut(host, ":")
+	if host != "" {
+		u = host + "/" + p
+	}
+	u = strings.TrimSuffix(strings.TrimSuffix(u, "/"), ".git")
+	return strings.ToLower(u)
+}
+
+func run(ctx context.Context, args ...string) ([]byte, error) {
+	out, err := exec.CommandContext(ctx, "git", args...).Output()
+	var exit *exec.ExitError
+	if errors.As(err, &exit) && len(exit.Stderr) > 0 {
+		return out, fmt.Errorf("git %s: %w: %s", args[2], err, bytes.TrimSpace(exit.Stderr))
+	}
+	return out, err
+}
+
+// reads the output of git log with format
+func Parse(r io.Reader, repo string) ([]Commit, error) {
+	var commits []Commit
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		if sc.Text() == "" {
+			continue
+		}
+		fields := strings.SplitN(sc.Text(), "\x1f", 4)
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("unexpected git log line %q", sc.Text())
+		}
+		unix, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("commit %s: %w", fields[0], err)
+		}
+		commits = append(commits, Commit{
+			Repo:    repo,
+			Hash:    fields[0],
+			Time:    time.Unix(unix, 0),
+			Author:  fields[2],
+			Subject: fields[3],
+		})
+	}
+	return commits, sc.Err()
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/chee-zer/negentropy/aggregate"
+	db "github.com/chee-zer/negentropy/database/sqlc"
+)
+
+// how far back streaks are looked for
+const streakWindowDays = 365
+
+type streak struct {
+	Current int
+	Best    int
+}
+
+func (s streak) String() string {
+	return fmt.Sprintf("streak %dd (best %dd)", s.Current, s.Best)
+}
+
+// streaks and weekly goal progress, recomputed together with today's totals
+type progress struct {
+	streaks map[int64]streak
+	// days on which at least one task reached its daily target
+	overall streak
+	goals   map[int64]time.Duration
+	// this week's tracked time without the running session, which is added live
+	week        map[int64]time.Duration
+	runningTask int64
+	runningFrom time.Time
+}
+
+func loadProgress(ctx context.Context, q db.Querier, tasks []db.Task, now time.Time) (progress, error) {
+	today := startOfDay(now)
+	from := today.AddDate(0, 0, -streakWindowDays)
+	spans, err := loadSpans(ctx, q, from, today.AddDate(0, 0, 1))
+	if err != nil {
+		return progress{}, err
+	}
+	p := progress{goals: make(map[int64]time.Duration), week: make(map[int64]time.Duration)}
+	p.streaks, p.overall = computeStreaks(dailyTotals(spans), tasks, from, today)
+
+	goals, err := q.GetGoals(ctx)
+	if err != nil {
+		return progress{}, err
+	}
+	for _, g := range goals {
+		p.goals[g.TaskID] = time.Duration(g.WeeklySeconds) * time.Second
+	}
+
+	weekStart := startOfWeek(now)
+	closed := make([]span, 0, len(spans))
+	for _, s := range spans {
+		if !s.Running {
+			closed = append(closed, s)
+		} else if !s.isEntropy() && !s.isBreak() {
+			p.runningTask = s.TaskID
+			p.runningFrom = s.Start
+			if p.runningFrom.Before(weekStart) {
+				p.runningFrom = weekStart
+			}
+		}
+	}
+	for id, d := range trackedTotals(closed, aggregate.Week)[weekStart] {
+		p.week[id] = d
+	}
+	return p, nil
+}
+
+// a task's streak counts days in a row its daily_target was met. Today only extends
+// the streak once it's met, until then the streak from yesterday is still current
+func computeStreaks(daily map[time.Time]map[int64]time.Duration, tasks []db.Task, from, today time.Time) (map[int64]streak, streak) {
+	streaks := make(map[int64]streak)
+	for _, t := range tasks {
+		if t.ID == entropyTaskID || !t.DailyTarget.Valid || t.DailyTarget.Int64 <= 0 {
+			continue
+		}
+		target := time.Duration(t.DailyTarget.Int64) * time.Second
+		streaks[t.ID] = runStreak(from, today, func(day time.Time) bool {
+			return daily[day][t.ID] >= target
+		})
+	}
+	overall := runStreak(from, today, func(day time.Time) bool {
+		for _, t := range tasks {
+			if _, ok := streaks[t.ID]; ok && daily[day][t.ID] >= time.Duration(t.DailyTarget.Int64)*time.Second {
+				return true
+			}
+		}
+		return false
+	})
+	return streaks, overall
+}
+
+func runStreak(from, today time.Time, met func(time.Time) bool) streak {
+	var s streak
+	run, untilYesterday := 0, 0
+	for day := from; !day.After(today); day = day.AddDate(0, 0, 1) {
+		if day.Equal(today) {
+			untilYesterday = run
+		}
+		if met(day) {
+			run++
+		} else {
+			run = 0
+		}
+		s.Best = max(s.Best, run)
+	}
+	s.Current = max(run, untilYesterday)
+	return s
+}
+
+func (m model) refreshProgress() model {
+	p, err := loadProgress(context.Background(), m.db, m.tabs.Tasks, time.Now())
+	if err != nil {
+		m.StatusQuote = "Couldn't load streaks: " + err.Error()
+		return m
+	}
+	m.progress = p
+	m.tabs.Streaks = p.streaks
+	m.tabs.Overall = p.overall
+	return m
+}
+
+// this week's tracked time for a task, including the running session
+func (p progress) weekTracked(taskID int64, now time.Time) time.Duration {
+	d := p.week[taskID]
+	if !p.runningFrom.IsZero() && p.runningTask == taskID && now.After(p.runningFrom) {
+		d += now.Sub(p.runningFrom)
+	}
+	return d
+}
+
+func (m model) goalView() string {
+	goal, ok := m.progress.goals[m.ActiveTaskId]
+	if !ok {
+		return ""
+	}
+	done := m.progress.weekTracked(m.ActiveTaskId, time.Now())
+	return fmt.Sprintf("week: %s / %s (%.0f%%)", formatDuration(done), formatDuration(goal), 100*done.Seconds()/goal.Seconds())
+}
+
+func renderGoals(w io.Writer, p progress, tasks []db.Task) {
+	fmt.Fprintf(w, "overall %s\n\n", p.overall)
+	for _, t := range tasks {
+		if t.ID == entropyTaskID {
+			continue
+		}
+		fmt.Fprintf(w, "  %-20s", t.Name)
+		if s, ok := p.streaks[t.ID]; ok {
+			fmt.Fprintf(w, " %-22s", s)
+		} else {
+			fmt.Fprintf(w, " %-22s", "no daily target")
+		}
+		if goal, ok := p.goals[t.ID]; ok {
+			done := p.weekTracked(t.ID, time.Now())
+			fmt.Fprintf(w, " week %s / %s %s", formatDuration(done), formatDuration(goal), bar(done.Seconds()/goal.Seconds(), 20))
+		}
+		fmt.Fprintln(w)
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	db "github.com/chee-zer/negentropy/database/sqlc"
+)
+
+// lightest to darkest, index 0 is a day with nothing tracked
+var heatShades = []string{"·", "░", "▒", "▓", "█"}
+
+type heatmapMode int
+
+const (
+	heatHours heatmapMode = iota
+	heatTarget
+)
+
+func (h heatmapMode) String() string {
+	if h == heatTarget {
+		return "target completion"
+	}
+	return "tracked hours"
+}
+
+// how dark a day is: share of the busiest day's hours, or how much of the daily targets was
+// reached (time over a task's target doesn't make up for another task)
+func dayIntensity(perTask map[int64]time.Duration, tasks []db.Task, mode heatmapMode, busiest time.Duration) float64 {
+	if mode == heatHours {
+		if busiest == 0 {
+			return 0
+		}
+		var total time.Duration
+		for _, d := range perTask {
+			total += d
+		}
+		return total.Seconds() / busiest.Seconds()
+	}
+	var done, target float64
+	for _, t := range tasks {
+		if t.ID == entropyTaskID || !t.DailyTarget.Valid || t.DailyTarget.Int64 <= 0 {
+			continue
+		}
+		target += float64(t.DailyTarget.Int64)
+		done += min(perTask[t.ID].Seconds(), float64(t.DailyTarget.Int64))
+	}
+	if target == 0 {
+		return 0
+	}
+	return done / target
+}
+
+func shade(intensity float64) string {
+	if intensity <= 0 {
+		return heatShades[0]
+	}
+	level := 1 + int(intensity*float64(len(heatShades)-1))
+	return heatShades[min(level, len(heatShades)-1)]
+}
+
+// one column per week (monday on top), days outside [from, to) are left blank
+func renderHeatmap(daily map[time.Time]map[int64]time.Duration, tasks []db.Task, from, to time.Time, mode heatmapMode) string {
+	var busiest, total time.Duration
+	for day, perTask := range daily {
+		if day.Before(from) || !day.Before(to) {
+			continue
+		}
+		var sum time.Duration
+		for _, d := range perTask {
+			sum += d
+		}
+		busiest = max(busiest, sum)
+		total += sum
+	}
+
+	first := startOfWeek(from)
+	days := int(to.Sub(first).Hours()/24 + 0.5)
+	weeks := (days + 6) / 7
+
+	var months strings.Builder
+	months.WriteString("    ")
+	for w := 0; w < weeks; {
+		sunday := first.AddDate(0, 0, 7*w+6)
+		// label the week containing the 1st
+		if sunday.Day() <= 7 && w+3 <= weeks {
+			label := sunday.Format("Jan")
+			months.WriteString(label)
+			w += len(label)
+			continue
+		}
+		months.WriteString(" ")
+		w++
+	}
+
+	var b strings.Builder
+	b.WriteString(strings.TrimRight(months.String(), " ") + "\n")
+	for wd := 0; wd < 7; wd++ {
+		label := "   "
+		if wd%2 == 0 {
+			label = first.AddDate(0, 0, wd).Format("Mon")
+		}
+		b.WriteString(label + " ")
+		for w := 0; w < weeks; w++ {
+			day := first.AddDate(0, 0, 7*w+wd)
+			if day.Before(from) || !day.Before(to) {
+				b.WriteString(" ")
+				continue
+			}
+			b.WriteString(shade(dayIntensity(daily[day], tasks, mode, busiest)))
+		}
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "\n    %s → %s, %s, %s tracked   less %s more\n",
+		from.Format(dateLayout), to.AddDate(0, 0, -1).Format(dateLayout), mode, formatDuration(total), strings.Join(heatShades, " "))
+	return b.String()
+}
+
+func loadHeatmap(ctx context.Context, q db.Querier, tasks []db.Task, from, to time.Time, mode heatmapMode) (string, error) {
+	spans, err := loadSpans(ctx, q, from, to)
+	if err != nil {
+		return "", err
+	}
+	return renderHeatmap(dailyTotals(spans), tasks, from, to, mode), nil
+}
+
+// the year ending on the day before end
+func heatmapRange(end time.Time) (time.Time, time.Time) {
+	return end.AddDate(-1, 0, 0), end
+}
+
+func (m model) openHeatmap() model {
+	if m.heatmapEnd.IsZero() {
+		m.heatmapEnd = startOfDay(time.Now()).AddDate(0, 0, 1)
+	}
+	from, to := heatmapRange(m.heatmapEnd)
+	heatmap, err := loadHeatmap(context.Background(), m.db, m.tabs.Tasks, from, to, m.heatmapMode)
+	if err != nil {
+		m.StatusQuote = "Couldn't load heatmap: " + err.Error()
+		return m
+	}
+	m.heatmap = heatmap
+	m.view = heatmapView
+	return m
+}
+
+// ←/→ move by a year, tab switches between hours and target completion
+func (m model) updateHeatmap(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, m.keymap.ToggleHeatmap), msg.Type == tea.KeyEsc:
+		m.view = mainView
+		return m, nil
+	case key.Matches(msg, m.keymap.GoLeft):
+		m.heatmapEnd = m.heatmapEnd.AddDate(-1, 0, 0)
+	case key.Matches(msg, m.keymap.GoRight):
+		m.heatmapEnd = m.heatmapEnd.AddDate(1, 0, 0)
+	case msg.Type == tea.KeyTab:
+		m.heatmapMode = (m.heatmapMode + 1) % 2
+	default:
+		return m, nil
+	}
+	return m.openHeatmap(), nil
+}
+
+func (m model) heatmapViewString() string {
+	return fmt.Sprintf("\n\n%s\n  %s\n\n  ←/→ year  tab hours/targets  esc back\n", m.heatmap, m.Timer.View())
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// a hook that hangs is killed after this long
+const hookTimeout = 30 * time.Second
+
+// runs the shell commands configured for an event with `sh -c`. The event is passed as JSON
+// on stdin and the common fields as NEGENTROPY_* environment variables. Commands run one at a
+// time in the order of their events, so a hook for a stop never races the start before it
+type hookRunner struct {
+	hooks map[string][]string
+	mu    sync.Mutex
+	queue []hookRun
+	// whether a goroutine is working through the queue
+	running bool
+	wg      sync.WaitGroup
+}
+
+type hookRun struct {
+	command string
+	env     []string
+	payload []byte
+}
+
+func newHookRunner(hooks map[string][]string) *hookRunner {
+	return &hookRunner{hooks: hooks}
+}
+
+// hooks for unknown events would silently never run
+func validateHooks(hooks map[string][]string) error {
+	for name := range hooks {
+		if !slices.Contains(eventNames, name) {
+			return fmt.Errorf("unknown hook event %q", name)
+		}
+	}
+	return nil
+}
+
+func (h *hookRunner) handle(ev event) {
+	commands := h.hooks[ev.Name]
+	if len(commands) == 0 {
+		return
+	}
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		log.Printf("hook %s: %v", ev.Name, err)
+		return
+	}
+	env := append(os.Environ(), hookEnv(ev)...)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, command := range commands {
+		h.queue = append(h.queue, hookRun{command: command, env: env, payload: payload})
+	}
+	h.wg.Add(len(commands))
+	if !h.running {
+		h.running = true
+		go h.work()
+	}
+}
+
+// runs queued commands until there are none left
+func (h *hookRunner) work() {
+	for {
+		h.mu.Lock()
+		if len(h.queue) == 0 {
+			h.running = false
+			h.mu.Unlock()
+			return
+		}
+		run := h.queue[0]
+		h.queue = h.queue[1:]
+		h.mu.Unlock()
+		runHook(run.command, run.env, run.payload)
+		h.wg.Done()
+	}
+}
+
+func (h *hookRunner) wait() {
+	h.wg.Wait()
+}
+
+func runHook(command string, env []string, payload []byte) {
+	ctx, cancel := context.WithTimeout(context.Background(), hookTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Env = env
+	cmd.Stdin = bytes.NewReader(payload)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		log.Printf("hook %q: %v: %s", command, err, bytes.TrimSpace(out))
+	}
+}
+
+func hookEnv(ev event) []string {
+	env := []string{
+		"NEGENTROPY_EVENT=" + ev.Name,
+		"NEGENTROPY_TIME=" + ev.Time.Format(time.RFC3339),
+		"NEGENTROPY_CAUSE=" + ev.Cause,
+	}
+	if ev.Task != nil {
+		env = append(env,
+			"NEGENTROPY_TASK="+ev.Task.Name,
+			"NEGENTROPY_TASK_ID="+strconv.FormatInt(ev.Task.ID, 10),
+		)
+	}
+	if s := ev.Session; s != nil {
+		env = append(env,
+			"NEGENTROPY_SESSION_ID="+strconv.FormatInt(s.ID, 10),
+			"NEGENTROPY_SESSION_START="+s.Start.Format(time.RFC3339),
+			"NEGENTROPY_DURATION_SECONDS="+strconv.FormatInt(s.DurationSeconds, 10),
+			"NEGENTROPY_ENTROPY_REASON="+s.EntropyReason,
+		)
+	}
+	return env
+}
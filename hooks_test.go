@@ -0,0 +1,38 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHooksInOrder(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh")
+	}
+	out := filepath.Join(t.TempDir(), "out")
+	// the hook of the first event is the slow one
+	slow := `[ "$NEGENTROPY_EVENT" = session_start ] && sleep 0.2; echo "$NEGENTROPY_EVENT 1" >> ` + out
+	h := newHookRunner(map[string][]string{
+		eventSessionStart: {slow, `echo "$NEGENTROPY_EVENT 2" >> ` + out},
+		eventSessionStop:  {slow},
+	})
+
+	begin := time.Now()
+	h.handle(event{Name: eventSessionStart})
+	h.handle(event{Name: eventSessionStop})
+	if d := time.Since(begin); d > 100*time.Millisecond {
+		t.Errorf("handle took %v, it shouldn't wait for the hooks", d)
+	}
+	h.wait()
+
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "session_start 1\nsession_start 2\nsession_stop 1\n"; string(got) != want {
+		t.Errorf("hooks ran as\n%swant\n%s", got, want)
+	}
+}
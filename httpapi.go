@@ -0,0 +1,418 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chee-zer/negentropy/aggregate"
+	"github.com/chee-zer/negentropy/daemon"
+	db "github.com/chee-zer/negentropy/database/sqlc"
+)
+
+// the HTTP/JSON api for editor plugins, macro pads and browser extensions. Durations are
+// whole seconds, times RFC 3339 like the export
+type httpAPI struct {
+	q      db.Querier
+	socket string
+	token  string // required as a bearer token when set
+	events *dispatcher
+	// start, stop and reset one at a time like the daemon does, without it two requests can
+	// both start a session
+	mu *sync.Mutex
+}
+
+type apiTask struct {
+	ID                 int64  `json:"id"`
+	Name               string `json:"name"`
+	Color              string `json:"color,omitempty"`
+	DailyTargetSeconds int64  `json:"daily_target_seconds,omitempty"`
+}
+
+type apiStatus struct {
+	Running        bool   `json:"running"`
+	SessionID      int64  `json:"session_id,omitempty"`
+	TaskID         int64  `json:"task_id,omitempty"`
+	Task           string `json:"task,omitempty"`
+	Start          string `json:"start,omitempty"`
+	ElapsedSeconds int64  `json:"elapsed_seconds,omitempty"`
+}
+
+type apiTaskTotal struct {
+	TaskID  int64  `json:"task_id"`
+	Task    string `json:"task"`
+	Seconds int64  `json:"seconds"`
+}
+
+type apiBucket struct {
+	Start  string         `json:"start"`
+	Tasks  []apiTaskTotal `json:"tasks"`
+	Total  int64          `json:"total_seconds"`
+	bucket time.Time
+}
+
+type apiGoal struct {
+	TaskID        int64  `json:"task_id"`
+	Task          string `json:"task"`
+	StreakCurrent int    `json:"streak_current"`
+	StreakBest    int    `json:"streak_best"`
+	WeeklySeconds int64  `json:"weekly_goal_seconds,omitempty"`
+	WeekSeconds   int64  `json:"week_seconds"`
+}
+
+func (a httpAPI) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /tasks", a.getTasks)
+	mux.HandleFunc("GET /status", a.getStatus)
+	mux.HandleFunc("POST /start", a.postStart)
+	mux.HandleFunc("POST /stop", a.postStop)
+	mux.HandleFunc("POST /reset", a.postReset)
+	mux.HandleFunc("GET /sessions", a.getSessions)
+	mux.HandleFunc("GET /reports/totals", a.getTotals)
+	mux.HandleFunc("GET /reports/goals", a.getGoals)
+	return a.guard(mux)
+}
+
+// without a token only requests from this machine to localhost are answered, the latter so a
+// web page can't reach the api through DNS rebinding. Requests from browsers (they carry an
+// Origin) are refused too, otherwise any open tab could stop the timer. With a token every
+// request has to carry it, and then it may come from anywhere
+func (a httpAPI) guard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.token != "" {
+			got := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(got), []byte(a.token)) != 1 {
+				writeError(w, http.StatusUnauthorized, errors.New("missing or wrong token"))
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !isLoopback(r.RemoteAddr) {
+			writeError(w, http.StatusForbidden, errors.New("set http_token to use the api from another machine"))
+			return
+		}
+		if !isLoopback(r.Host) {
+			writeError(w, http.StatusForbidden, errors.New("host not allowed"))
+			return
+		}
+		if r.Header.Get("Origin") != "" {
+			writeError(w, http.StatusForbidden, errors.New("set http_token to use the api from a browser"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// localhost or a loopback ip, with or without a port
+func isLoopback(hostport string) bool {
+	host := hostport
+	if h, _, err := net.SplitHostPort(hostport); err == nil {
+		host = h
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(strings.Trim(host, "[]"))
+	return ip != nil && ip.IsLoopback()
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("http api: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// the daemon's timer if it's running, connected per request so restarting the daemon is fine
+func (a httpAPI) timer() (daemon.Timer, func()) {
+	timer, attached := connectTimer(a.q, a.socket, a.events)
+	if !attached {
+		return timer, func() {}
+	}
+	return timer, func() { timer.(io.Closer).Close() }
+}
+
+func (a httpAPI) tasks(ctx context.Context) ([]db.Task, map[int64]db.Task, error) {
+	tasks, err := a.q.GetTasks(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	taskMap := make(map[int64]db.Task, len(tasks))
+	for _, t := range tasks {
+		taskMap[t.ID] = t
+	}
+	return tasks, taskMap, nil
+}
+
+func (a httpAPI) getTasks(w http.ResponseWriter, r *http.Request) {
+	tasks, _, err := a.tasks(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	out := make([]apiTask, 0, len(tasks))
+	for _, t := range tasks {
+		out = append(out, apiTask{ID: t.ID, Name: t.Name, Color: t.ColorHex.String, DailyTargetSeconds: t.DailyTarget.Int64})
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (a httpAPI) statusOf(ctx context.Context, status daemon.Status) (apiStatus, error) {
+	if !status.Running() {
+		return apiStatus{}, nil
+	}
+	_, taskMap, err := a.tasks(ctx)
+	if err != nil {
+		return apiStatus{}, err
+	}
+	s := status.Session
+	start, err := parseTimestamp(s.StartTime)
+	if err != nil {
+		return apiStatus{}, err
+	}
+	return apiStatus{
+		Running:        true,
+		SessionID:      s.ID,
+		TaskID:         s.TaskID,
+		Task:           taskName(taskMap, s.TaskID),
+		Start:          start.Format(time.RFC3339),
+		ElapsedSeconds: int64(time.Since(start).Seconds()),
+	}, nil
+}
+
+// answers with the timer status after an operation, ErrRunning and ErrNotRunning are conflicts
+func (a httpAPI) writeStatus(w http.ResponseWriter, r *http.Request, status daemon.Status, err error) {
+	switch {
+	case errors.Is(err, daemon.ErrRunning), errors.Is(err, daemon.ErrNotRunning):
+		writeError(w, http.StatusConflict, err)
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	out, err := a.statusOf(r.Context(), status)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (a httpAPI) getStatus(w http.ResponseWriter, r *http.Request) {
+	timer, done := a.timer()
+	defer done()
+	status, err := timer.Status(r.Context())
+	a.writeStatus(w, r, status, err)
+}
+
+// body {"task": "name or id"}
+func (a httpAPI) postStart(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Task string `json:"task"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	tasks, _, err := a.tasks(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	task, err := findTask(tasks, body.Task)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	timer, done := a.timer()
+	defer done()
+	a.mu.Lock()
+	status, err := timer.Start(r.Context(), task.ID, time.Now())
+	a.mu.Unlock()
+	a.writeStatus(w, r, status, err)
+}
+
+func (a httpAPI) postStop(w http.ResponseWriter, r *http.Request) {
+	timer, done := a.timer()
+	defer done()
+	a.mu.Lock()
+	status, err := timer.Stop(r.Context(), time.Now())
+	a.mu.Unlock()
+	a.writeStatus(w, r, status, err)
+}
+
+// optional body {"reason": "..."}
+func (a httpAPI) postReset(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	timer, done := a.timer()
+	defer done()
+	a.mu.Lock()
+	status, err := timer.Reset(r.Context(), time.Now(), strings.TrimSpace(body.Reason))
+	a.mu.Unlock()
+	a.writeStatus(w, r, status, err)
+}
+
+// ?from=YYYY-MM-DD&to=YYYY-MM-DD, both inclusive, defaults to the last 7 days
+func queryRange(r *http.Request) (time.Time, time.Time, error) {
+	today := startOfDay(time.Now())
+	from, err := parseDate(r.URL.Query().Get("from"), today.AddDate(0, 0, -6))
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	to, err := parseDate(r.URL.Query().Get("to"), today)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return from, to.AddDate(0, 0, 1), nil
+}
+
+func (a httpAPI) getSessions(w http.ResponseWriter, r *http.Request) {
+	from, to, err := queryRange(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	sessions, err := a.q.GetSessionsInRange(r.Context(), db.GetSessionsInRangeParams{
+		RangeStart: formatTimestamp(from),
+		RangeEnd:   formatTimestamp(to),
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	_, taskMap, err := a.tasks(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	rows, err := toExportRows(sessions, taskMap, time.Now())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, rows)
+}
+
+var apiUnits = map[string]aggregate.Unit{
+	"hour":  aggregate.Hour,
+	"day":   aggregate.Day,
+	"week":  aggregate.Week,
+	"month": aggregate.Month,
+}
+
+// tracked time per bucket and task, ?unit=hour|day|week|month (default day) plus the range
+func (a httpAPI) getTotals(w http.ResponseWriter, r *http.Request) {
+	from, to, err := queryRange(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	unit := aggregate.Day
+	if name := r.URL.Query().Get("unit"); name != "" {
+		var ok bool
+		if unit, ok = apiUnits[name]; !ok {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("unknown unit %q, expected hour, day, week or month", name))
+			return
+		}
+	}
+	spans, err := loadSpans(r.Context(), a.q, from, to)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	_, taskMap, err := a.tasks(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	out := []apiBucket{}
+	for bucket, perTask := range trackedTotals(spans, unit) {
+		b := apiBucket{Start: bucket.Format(time.RFC3339), bucket: bucket}
+		for id, d := range perTask {
+			b.Tasks = append(b.Tasks, apiTaskTotal{TaskID: id, Task: taskName(taskMap, id), Seconds: int64(d.Seconds())})
+			b.Total += int64(d.Seconds())
+		}
+		slices.SortFunc(b.Tasks, func(x, y apiTaskTotal) int { return cmp.Compare(x.TaskID, y.TaskID) })
+		out = append(out, b)
+	}
+	slices.SortFunc(out, func(x, y apiBucket) int { return x.bucket.Compare(y.bucket) })
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (a httpAPI) getGoals(w http.ResponseWriter, r *http.Request) {
+	tasks, _, err := a.tasks(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	now := time.Now()
+	p, err := loadProgress(r.Context(), a.q, tasks, now)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	out := []apiGoal{}
+	for _, t := range tasks {
+		if t.ID == entropyTaskID {
+			continue
+		}
+		s := p.streaks[t.ID]
+		out = append(out, apiGoal{
+			TaskID:        t.ID,
+			Task:          t.Name,
+			StreakCurrent: s.Current,
+			StreakBest:    s.Best,
+			WeeklySeconds: int64(p.goals[t.ID].Seconds()),
+			WeekSeconds:   int64(p.weekTracked(t.ID, now).Seconds()),
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"overall_streak_current": p.overall.Current,
+		"overall_streak_best":    p.overall.Best,
+		"tasks":                  out,
+	})
+}
+
+// serves the api on addr until ctx is done. Only on loopback unless there's a token, anyone
+// who can reach it could control the timer otherwise
+func serveHTTP(ctx context.Context, addr string, api httpAPI) error {
+	if host, _, err := net.SplitHostPort(addr); err != nil {
+		return err
+	} else if api.token == "" && !isLoopback(host) {
+		return fmt.Errorf("%s isn't a loopback address, set http_token to serve the api to other machines", addr)
+	}
+	srv := &http.Server{Addr: addr, Handler: api.handler(), ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdown)
+	}()
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestHTTPGuard(t *testing.T) {
+	tests := []struct {
+		name   string
+		token  string
+		remote string
+		host   string
+		header map[string]string
+		want   int
+	}{
+		{name: "local", remote: "127.0.0.1:5000", host: "localhost:7788", want: http.StatusOK},
+		{name: "local ipv6", remote: "[::1]:5000", host: "[::1]:7788", want: http.StatusOK},
+		{name: "other machine", remote: "192.168.1.20:5000", host: "localhost:7788", want: http.StatusForbidden},
+		{name: "rebound host", remote: "127.0.0.1:5000", host: "evil.example:7788", want: http.StatusForbidden},
+		{name: "browser", remote: "127.0.0.1:5000", host: "localhost:7788", header: map[string]string{"Origin": "https://example.com"}, want: http.StatusForbidden},
+		{name: "no token", token: "t0k", remote: "127.0.0.1:5000", host: "localhost:7788", want: http.StatusUnauthorized},
+		{name: "wrong token", token: "t0k", remote: "127.0.0.1:5000", host: "localhost:7788", header: map[string]string{"Authorization": "Bearer nope"}, want: http.StatusUnauthorized},
+		{name: "token from another machine", token: "t0k", remote: "192.168.1.20:5000", host: "desk.lan:7788", header: map[string]string{"Authorization": "Bearer t0k"}, want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+			r := httptest.NewRequest(http.MethodPost, "/stop", nil)
+			r.RemoteAddr, r.Host = tt.remote, tt.host
+			for k, v := range tt.header {
+				r.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			httpAPI{token: tt.token}.guard(ok).ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Errorf("status %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
+
+func TestServeHTTPAddr(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, addr := range []string{"0.0.0.0:7788", ":7788", "192.168.1.20:7788"} {
+		if err := serveHTTP(ctx, addr, httpAPI{mu: &sync.Mutex{}}); err == nil {
+			t.Errorf("served on %s without a token", addr)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package ical
+
+import (
+	"io"
+	"strings"
+	"time"
+)
+
+// a single VEVENT, times are written in UTC
+type Event struct {
+	UID         string
+	Start       time.Time
+	End         time.Time
+	Summary     string
+	Description string
+	Categories  []string
+	// the start the recurrence rule gave this occurrence, zero for events that don't recur
+	RecurrenceID time.Time
+}
+
+type Calendar struct {
+	Name string
+	// #RRGGBB, written as X-APPLE-CALENDAR-COLOR which most calendar apps pick up
+	Color  string
+	Events []Event
+}
+
+const utcLayout = "20060102T150405Z"
+
+// writes the calendar as an RFC 5545 VCALENDAR with CRLF line endings and folded lines
+func (c Calendar) Encode(w io.Writer, stamp time.Time) error {
+	e := &encoder{w: w}
+	e.line("BEGIN:VCALENDAR")
+	e.line("VERSION:2.0")
+	e.line("PRODID:-//negentropy//negentropy//EN")
+	e.line("CALSCALE:GREGORIAN")
+	if c.Name != "" {
+		e.line("X-WR-CALNAME:" + escape(c.Name))
+	}
+	if c.Color != "" {
+		e.line("X-APPLE-CALENDAR-COLOR:" + c.Color)
+	}
+	for _, ev := range c.Events {
+		e.line("BEGIN:VEVENT")
+		e.line("UID:" + escape(ev.UID))
+		e.line("DTSTAMP:" + stamp.UTC().Format(utcLayout))
+		e.line("DTSTART:" + ev.Start.UTC().Format(utcLayout))
+		e.line("DTEND:" + ev.End.UTC().Format(utcLayout))
+		e.line("SUMMARY:" + escape(ev.Summary))
+		if ev.Description != "" {
+			e.line("DESCRIPTION:" + escape(ev.Description))
+		}
+		if len(ev.Categories) > 0 {
+			cats := make([]string, len(ev.Categories))
+			for i, c := range ev.Categories {
+				cats[i] = escape(c)
+			}
+			e.line("CATEGORIES:" + strings.Join(cats, ","))
+		}
+		e.line("END:VEVENT")
+	}
+	e.line("END:VCALENDAR")
+	return e.err
+}
+
+type encoder struct {
+	w   io.Writer
+	err error
+}
+
+// lines longer than 75 octets are folded, continuation lines start with a space.
+// Folding never splits a multi-byte character
+func (e *encoder) line(s string) {
+	if e.err != nil {
+		return
+	}
+	var b strings.Builder
+	limit := 75
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !startsRune(s[cut]) {
+			cut--
+		}
+		b.WriteString(s[:cut])
+		b.WriteString("\r\n ")
+		s = s[cut:]
+		limit = 74
+	}
+	b.WriteString(s)
+	b.WriteString("\r\n")
+	_, e.err = io.WriteString(e.w, b.String())
+}
+
+func startsRune(b byte) bool {
+	return b&0xC0 != 0x80
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+// TEXT value escaping from RFC 5545 3.3.11
+func escape(s string) string {
+	return escaper.Replace(s)
+}
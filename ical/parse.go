@@ -0,0 +1,274 @@
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// reads the VEVENTs of an .ics file. Floating times and unknown TZIDs are read in loc,
+// all-day events span whole days. Recurring events are expanded to their occurrences that
+// start between from and to, with a moved or changed occurrence (a VEVENT with a
+// RECURRENCE-ID) in place of the one it overrides
+func Parse(r io.Reader, loc *time.Location, from, to time.Time) ([]Event, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+	var vevents []vevent
+	var cur *vevent
+	var duration string
+	for n, l := range lines {
+		name, params, value, ok := splitLine(l)
+		if !ok {
+			continue
+		}
+		switch {
+		case name == "BEGIN" && value == "VEVENT":
+			cur = &vevent{}
+			duration = ""
+		case name == "END" && value == "VEVENT" && cur != nil:
+			if cur.End.IsZero() && duration != "" {
+				d, err := parseDuration(duration)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %w", n+1, err)
+				}
+				cur.End = cur.Start.Add(d)
+			}
+			if !cur.Start.IsZero() && cur.End.After(cur.Start) {
+				vevents = append(vevents, *cur)
+			}
+			cur = nil
+		case cur == nil:
+		case name == "UID":
+			cur.UID = value
+		case name == "SUMMARY":
+			cur.Summary = unescape(value)
+		case name == "DESCRIPTION":
+			cur.Description = unescape(value)
+		case name == "CATEGORIES":
+			for _, c := range splitList(value) {
+				cur.Categories = append(cur.Categories, unescape(c))
+			}
+		case name == "DTSTART", name == "DTEND", name == "RECURRENCE-ID":
+			t, allDay, err := parseTime(value, params, loc)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n+1, err)
+			}
+			switch name {
+			case "DTSTART":
+				cur.Start, cur.allDay = t, allDay
+				// an all-day event without DTEND lasts one day
+				if allDay && cur.End.IsZero() && duration == "" {
+					duration = "P1D"
+				}
+			case "DTEND":
+				cur.End = t
+			default:
+				cur.RecurrenceID = t
+			}
+		case name == "DURATION":
+			duration = value
+		case name == "RRULE":
+			cur.rrule, cur.ruleLine = value, n+1
+		case name == "RDATE", name == "EXDATE":
+			if params["VALUE"] == "PERIOD" {
+				return nil, fmt.Errorf("line %d: %s periods aren't supported", n+1, name)
+			}
+			for _, v := range strings.Split(value, ",") {
+				t, _, err := parseTime(v, params, loc)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %w", n+1, err)
+				}
+				if name == "RDATE" {
+					cur.rdates = append(cur.rdates, t)
+				} else {
+					cur.exdates = append(cur.exdates, t)
+				}
+			}
+		}
+	}
+	return expand(vevents, loc, from, to)
+}
+
+// a VEVENT as read, before its recurrence is expanded
+type vevent struct {
+	Event
+	allDay   bool
+	rrule    string
+	ruleLine int
+	rdates   []time.Time
+	exdates  []time.Time
+}
+
+// events that don't recur as they are, the occurrences of recurring ones between from and
+// to, and the overrides of single occurrences that start between from and to
+func expand(vevents []vevent, loc *time.Location, from, to time.Time) ([]Event, error) {
+	overridden := make(map[string]bool)
+	for _, v := range vevents {
+		if !v.RecurrenceID.IsZero() {
+			overridden[occurrenceKey(v.UID, v.RecurrenceID)] = true
+		}
+	}
+	var events []Event
+	for _, v := range vevents {
+		if !v.RecurrenceID.IsZero() {
+			if inWindow(v.Start, from, to) {
+				events = append(events, v.Event)
+			}
+			continue
+		}
+		if v.rrule == "" && len(v.rdates) == 0 {
+			events = append(events, v.Event)
+			continue
+		}
+		starts := []time.Time{v.Start}
+		if v.rrule != "" {
+			rl, err := parseRule(v.rrule, loc)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: can't expand the RRULE of %q: %w", v.ruleLine, v.Summary, err)
+			}
+			starts = rl.starts(v.Start, to)
+		}
+		starts = append(starts, v.rdates...)
+		sort.Slice(starts, func(i, j int) bool { return starts[i].Before(starts[j]) })
+		for i, start := range starts {
+			if !inWindow(start, from, to) || (i > 0 && start.Equal(starts[i-1])) || overridden[occurrenceKey(v.UID, start)] ||
+				slices.ContainsFunc(v.exdates, start.Equal) {
+				continue
+			}
+			ev := v.Event
+			ev.Start, ev.End, ev.RecurrenceID = start, v.endFor(start), start
+			events = append(events, ev)
+		}
+	}
+	return events, nil
+}
+
+// an occurrence lasts as long as the first one, all-day ones the same number of days
+func (v vevent) endFor(start time.Time) time.Time {
+	if v.allDay {
+		days := v.End.Sub(v.Start).Round(24*time.Hour) / (24 * time.Hour)
+		return start.AddDate(0, 0, int(days))
+	}
+	return start.Add(v.End.Sub(v.Start))
+}
+
+func occurrenceKey(uid string, recurrenceID time.Time) string {
+	return uid + "/" + recurrenceID.UTC().Format(utcLayout)
+}
+
+func inWindow(t, from, to time.Time) bool {
+	return !t.Before(from) && t.Before(to)
+}
+
+// joins folded lines back together
+func unfold(r io.Reader) ([]string, error) {
+	var lines []string
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		l := strings.TrimRight(sc.Text(), "\r")
+		if (strings.HasPrefix(l, " ") || strings.HasPrefix(l, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += l[1:]
+			continue
+		}
+		lines = append(lines, l)
+	}
+	return lines, sc.Err()
+}
+
+// NAME;PARAM=x;PARAM2=y:value, quoted parameter values may contain ':' and ';'
+func splitLine(l string) (string, map[string]string, string, bool) {
+	inQuote := false
+	colon := -1
+	for i, c := range l {
+		if c == '"' {
+			inQuote = !inQuote
+		}
+		if c == ':' && !inQuote {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return "", nil, "", false
+	}
+	parts := strings.Split(l[:colon], ";")
+	params := make(map[string]string)
+	for _, p := range parts[1:] {
+		if k, v, ok := strings.Cut(p, "="); ok {
+			params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+		}
+	}
+	return strings.ToUpper(parts[0]), params, l[colon+1:], true
+}
+
+func parseTime(value string, params map[string]string, loc *time.Location) (time.Time, bool, error) {
+	if params["VALUE"] == "DATE" || len(value) == len("20060102") {
+		t, err := time.ParseInLocation("20060102", value, loc)
+		return t, true, err
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse(utcLayout, value)
+		return t, false, err
+	}
+	if tzid, ok := params["TZID"]; ok {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		}
+	}
+	t, err := time.ParseInLocation("20060102T150405", value, loc)
+	return t, false, err
+}
+
+var durationRe = regexp.MustCompile(`^([+-])?P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// RFC 5545 durations like PT1H30M or P1D
+func parseDuration(s string) (time.Duration, error) {
+	m := durationRe.FindStringSubmatch(s)
+	if m == nil {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	units := []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second}
+	var d time.Duration
+	for i, u := range units {
+		if m[i+2] == "" {
+			continue
+		}
+		n, _ := strconv.Atoi(m[i+2])
+		d += time.Duration(n) * u
+	}
+	if m[1] == "-" {
+		d = -d
+	}
+	return d, nil
+}
+
+// splits a list value on the commas that aren't escaped
+func splitList(s string) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case ',':
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+var unescaper = strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n")
+
+func unescape(s string) string {
+	return unescaper.Replace(s)
+}
@@ -0,0 +1,209 @@
+package ical
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("no zone %s: %v", name, err)
+	}
+	return loc
+}
+
+// a VCALENDAR around the given VEVENT lines, with CRLF line endings
+func calendar(events ...string) string {
+	var b strings.Builder
+	b.WriteString("BEGIN:VCALENDAR\r\nVERSION:2.0\r\n")
+	for _, ev := range events {
+		b.WriteString("BEGIN:VEVENT\r\n")
+		b.WriteString(strings.ReplaceAll(strings.TrimSpace(ev), "\n", "\r\n") + "\r\n")
+		b.WriteString("END:VEVENT\r\n")
+	}
+	b.WriteString("END:VCALENDAR\r\n")
+	return b.String()
+}
+
+func TestParse(t *testing.T) {
+	berlin := mustLoad(t, "Europe/Berlin")
+	ny := mustLoad(t, "America/New_York")
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, berlin)
+	to := from.AddDate(1, 0, 0)
+	at := func(loc *time.Location, y int, m time.Month, d, h, mi int) time.Time {
+		return time.Date(y, m, d, h, mi, 0, 0, loc)
+	}
+
+	tests := []struct {
+		name string
+		ics  string
+		want []Event
+	}{
+		{
+			name: "utc with folded and escaped text",
+			ics: calendar(`
+UID:a
+DTSTART:20260310T090000Z
+DTEND:20260310T100000Z
+SUMMARY:write the\, long
+  report
+DESCRIPTION:line one\nline two
+CATEGORIES:work,deep\, focus`),
+			want: []Event{{UID: "a", Start: at(time.UTC, 2026, 3, 10, 9, 0), End: at(time.UTC, 2026, 3, 10, 10, 0),
+				Summary: "write the, long report", Description: "line one\nline two", Categories: []string{"work", "deep, focus"}}},
+		},
+		{
+			name: "tzid, floating and unknown tzid",
+			ics: calendar(`
+UID:ny
+DTSTART;TZID="America/New_York":20260310T090000
+DTEND;TZID=America/New_York:20260310T093000`, `
+UID:floating
+DTSTART:20260310T090000
+DTEND:20260310T093000`, `
+UID:unknown
+DTSTART;TZID=Custom Zone:20260310T090000
+DTEND;TZID=Custom Zone:20260310T093000`),
+			want: []Event{
+				{UID: "ny", Start: at(ny, 2026, 3, 10, 9, 0), End: at(ny, 2026, 3, 10, 9, 30)},
+				{UID: "floating", Start: at(berlin, 2026, 3, 10, 9, 0), End: at(berlin, 2026, 3, 10, 9, 30)},
+				{UID: "unknown", Start: at(berlin, 2026, 3, 10, 9, 0), End: at(berlin, 2026, 3, 10, 9, 30)},
+			},
+		},
+		{
+			name: "date only",
+			ics: calendar(`
+UID:day
+DTSTART;VALUE=DATE:20260310`, `
+UID:days
+DTSTART;VALUE=DATE:20260310
+DTEND;VALUE=DATE:20260312`),
+			want: []Event{
+				{UID: "day", Start: at(berlin, 2026, 3, 10, 0, 0), End: at(berlin, 2026, 3, 11, 0, 0)},
+				{UID: "days", Start: at(berlin, 2026, 3, 10, 0, 0), End: at(berlin, 2026, 3, 12, 0, 0)},
+			},
+		},
+		{
+			name: "duration",
+			ics: calendar(`
+UID:d
+DTSTART:20260310T090000Z
+DURATION:PT1H30M`, `
+UID:w
+DTSTART:20260310T090000Z
+DURATION:P1W2DT1S`),
+			want: []Event{
+				{UID: "d", Start: at(time.UTC, 2026, 3, 10, 9, 0), End: at(time.UTC, 2026, 3, 10, 10, 30)},
+				{UID: "w", Start: at(time.UTC, 2026, 3, 10, 9, 0), End: at(time.UTC, 2026, 3, 19, 9, 0).Add(time.Second)},
+			},
+		},
+		{
+			name: "no start or no length",
+			ics: calendar(`
+UID:nostart
+DTEND:20260310T090000Z`, `
+UID:empty
+DTSTART:20260310T090000Z
+DTEND:20260310T090000Z`),
+		},
+		{
+			name: "weekly by day with a count, across the dst change",
+			ics: calendar(`
+UID:standup
+DTSTART;TZID=Europe/Berlin:20260325T093000
+DTEND;TZID=Europe/Berlin:20260325T094500
+RRULE:FREQ=WEEKLY;BYDAY=MO,WE;COUNT=4
+SUMMARY:standup`),
+			want: []Event{
+				{UID: "standup", Start: at(berlin, 2026, 3, 25, 9, 30), End: at(berlin, 2026, 3, 25, 9, 45), RecurrenceID: at(berlin, 2026, 3, 25, 9, 30), Summary: "standup"},
+				{UID: "standup", Start: at(berlin, 2026, 3, 30, 9, 30), End: at(berlin, 2026, 3, 30, 9, 45), RecurrenceID: at(berlin, 2026, 3, 30, 9, 30), Summary: "standup"},
+				{UID: "standup", Start: at(berlin, 2026, 4, 1, 9, 30), End: at(berlin, 2026, 4, 1, 9, 45), RecurrenceID: at(berlin, 2026, 4, 1, 9, 30), Summary: "standup"},
+				{UID: "standup", Start: at(berlin, 2026, 4, 6, 9, 30), End: at(berlin, 2026, 4, 6, 9, 45), RecurrenceID: at(berlin, 2026, 4, 6, 9, 30), Summary: "standup"},
+			},
+		},
+		{
+			name: "every other week until a date, started before the window",
+			ics: calendar(`
+UID:review
+DTSTART:20251222T150000Z
+DTEND:20251222T160000Z
+RRULE:FREQ=WEEKLY;INTERVAL=2;UNTIL=20260119`),
+			want: []Event{
+				{UID: "review", Start: at(time.UTC, 2026, 1, 5, 15, 0), End: at(time.UTC, 2026, 1, 5, 16, 0), RecurrenceID: at(time.UTC, 2026, 1, 5, 15, 0)},
+				{UID: "review", Start: at(time.UTC, 2026, 1, 19, 15, 0), End: at(time.UTC, 2026, 1, 19, 16, 0), RecurrenceID: at(time.UTC, 2026, 1, 19, 15, 0)},
+			},
+		},
+		{
+			name: "monthly on the 31st skips short months",
+			ics: calendar(`
+UID:rent
+DTSTART;VALUE=DATE:20260131
+RRULE:FREQ=MONTHLY;COUNT=3`),
+			want: []Event{
+				{UID: "rent", Start: at(berlin, 2026, 1, 31, 0, 0), End: at(berlin, 2026, 2, 1, 0, 0), RecurrenceID: at(berlin, 2026, 1, 31, 0, 0)},
+				{UID: "rent", Start: at(berlin, 2026, 3, 31, 0, 0), End: at(berlin, 2026, 4, 1, 0, 0), RecurrenceID: at(berlin, 2026, 3, 31, 0, 0)},
+				{UID: "rent", Start: at(berlin, 2026, 5, 31, 0, 0), End: at(berlin, 2026, 6, 1, 0, 0), RecurrenceID: at(berlin, 2026, 5, 31, 0, 0)},
+			},
+		},
+		{
+			name: "exdate, rdate and a moved occurrence",
+			ics: calendar(`
+UID:gym
+DTSTART:20260302T070000Z
+DTEND:20260302T080000Z
+RRULE:FREQ=DAILY;BYDAY=MO,TU;UNTIL=20260310T070000Z
+EXDATE:20260303T070000Z
+RDATE:20260307T070000Z
+SUMMARY:gym`, `
+UID:gym
+RECURRENCE-ID:20260309T070000Z
+DTSTART:20260309T180000Z
+DTEND:20260309T190000Z
+SUMMARY:gym, late`),
+			want: []Event{
+				{UID: "gym", Start: at(time.UTC, 2026, 3, 2, 7, 0), End: at(time.UTC, 2026, 3, 2, 8, 0), RecurrenceID: at(time.UTC, 2026, 3, 2, 7, 0), Summary: "gym"},
+				{UID: "gym", Start: at(time.UTC, 2026, 3, 7, 7, 0), End: at(time.UTC, 2026, 3, 7, 8, 0), RecurrenceID: at(time.UTC, 2026, 3, 7, 7, 0), Summary: "gym"},
+				{UID: "gym", Start: at(time.UTC, 2026, 3, 10, 7, 0), End: at(time.UTC, 2026, 3, 10, 8, 0), RecurrenceID: at(time.UTC, 2026, 3, 10, 7, 0), Summary: "gym"},
+				{UID: "gym", Start: at(time.UTC, 2026, 3, 9, 18, 0), End: at(time.UTC, 2026, 3, 9, 19, 0), RecurrenceID: at(time.UTC, 2026, 3, 9, 7, 0), Summary: "gym, late"},
+			},
+		},
+		{
+			name: "yearly without an end stops at the window",
+			ics: calendar(`
+UID:bday
+DTSTART;VALUE=DATE:20200229
+RRULE:FREQ=YEARLY`),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(strings.NewReader(tt.ics), berlin, from, to)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d events, want %d: %+v", len(got), len(tt.want), got)
+			}
+			for i, w := range tt.want {
+				g := got[i]
+				if g.UID != w.UID || !g.Start.Equal(w.Start) || !g.End.Equal(w.End) || !g.RecurrenceID.Equal(w.RecurrenceID) ||
+					g.Summary != w.Summary || g.Description != w.Description || strings.Join(g.Categories, "|") != strings.Join(w.Categories, "|") {
+					t.Errorf("event %d = %+v\nwant %+v", i, g, w)
+				}
+			}
+		})
+	}
+}
+
+func TestParseUnsupportedRule(t *testing.T) {
+	for _, rrule := range []string{"FREQ=MONTHLY;BYDAY=1MO", "FREQ=WEEKLY;BYSETPOS=-1;BYDAY=FR", "FREQ=HOURLY", "FREQ=DAILY;INTERVAL=0"} {
+		ics := calendar("UID:x\nSUMMARY:planning\nDTSTART:20260302T070000Z\nDTEND:20260302T080000Z\nRRULE:" + rrule)
+		_, err := Parse(strings.NewReader(ics), time.UTC, time.Time{}, time.Now())
+		if err == nil || !strings.Contains(err.Error(), `"planning"`) {
+			t.Errorf("%s: err = %v", rrule, err)
+		}
+	}
+}
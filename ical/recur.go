@@ -0,0 +1,163 @@
+package ical
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// the part of an RRULE that's supported: a FREQ with INTERVAL, COUNT or UNTIL and plain BYDAY
+// weekdays. Rules with anything else are refused rather than planned wrong
+type rule struct {
+	freq     string
+	interval int
+	count    int
+	// the last start the rule allows, zero for none
+	until time.Time
+	byDay []time.Weekday
+	wkst  time.Weekday
+}
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+func parseRule(s string, loc *time.Location) (rule, error) {
+	rl := rule{interval: 1, wkst: time.Monday}
+	for _, part := range strings.Split(s, ";") {
+		k, v, _ := strings.Cut(part, "=")
+		var err error
+		switch strings.ToUpper(k) {
+		case "FREQ":
+			rl.freq = strings.ToUpper(v)
+		case "INTERVAL":
+			rl.interval, err = strconv.Atoi(v)
+			if err == nil && rl.interval < 1 {
+				err = fmt.Errorf("INTERVAL %d", rl.interval)
+			}
+		case "COUNT":
+			rl.count, err = strconv.Atoi(v)
+			if err == nil && rl.count < 1 {
+				err = fmt.Errorf("COUNT %d", rl.count)
+			}
+		case "UNTIL":
+			var allDay bool
+			rl.until, allDay, err = parseTime(v, nil, loc)
+			// a date until includes that whole day
+			if allDay {
+				rl.until = rl.until.AddDate(0, 0, 1).Add(-time.Nanosecond)
+			}
+		case "BYDAY":
+			for _, d := range strings.Split(v, ",") {
+				wd, ok := weekdays[strings.ToUpper(d)]
+				if !ok {
+					return rl, fmt.Errorf("BYDAY %s isn't supported", d)
+				}
+				rl.byDay = append(rl.byDay, wd)
+			}
+		case "WKST":
+			wd, ok := weekdays[strings.ToUpper(v)]
+			if !ok {
+				err = fmt.Errorf("WKST %s", v)
+			}
+			rl.wkst = wd
+		default:
+			return rl, fmt.Errorf("%s isn't supported", k)
+		}
+		if err != nil {
+			return rl, err
+		}
+	}
+	switch rl.freq {
+	case "DAILY", "WEEKLY":
+	case "MONTHLY", "YEARLY":
+		if len(rl.byDay) > 0 {
+			return rl, fmt.Errorf("BYDAY with FREQ=%s isn't supported", rl.freq)
+		}
+	default:
+		return rl, fmt.Errorf("FREQ=%s isn't supported", rl.freq)
+	}
+	return rl, nil
+}
+
+// the starts the rule generates before to, start itself counting as the first one. Later
+// starts keep the wall clock time of start in its location
+func (rl rule) starts(start, to time.Time) []time.Time {
+	if rl.freq == "WEEKLY" && len(rl.byDay) == 0 {
+		rl.byDay = []time.Weekday{start.Weekday()}
+	}
+	var out []time.Time
+	// false once the rule or the window has ended
+	emit := func(t time.Time) bool {
+		if !t.Before(to) || (!rl.until.IsZero() && t.After(rl.until)) || (rl.count > 0 && len(out) == rl.count) {
+			return false
+		}
+		if t.Equal(start) && len(out) == 0 || t.After(start) && rl.onDay(t) {
+			out = append(out, t)
+		}
+		return true
+	}
+	if !emit(start) {
+		return out
+	}
+	y, m, d := start.Date()
+	h, mi, s := start.Clock()
+	at := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, h, mi, s, start.Nanosecond(), start.Location())
+	}
+	// the first day of the week start is in
+	ws := d - (int(start.Weekday())-int(rl.wkst)+7)%7
+	for k := 0; ; k++ {
+		n := k * rl.interval
+		switch rl.freq {
+		case "DAILY":
+			if !emit(at(y, m, d+n)) {
+				return out
+			}
+		case "WEEKLY":
+			for i := range 7 {
+				if !emit(at(y, m, ws+7*n+i)) {
+					return out
+				}
+			}
+		case "MONTHLY":
+			t := at(y, m+time.Month(n), d)
+			// months without the day are skipped
+			if t.Day() != d {
+				if !t.Before(to) {
+					return out
+				}
+				continue
+			}
+			if !emit(t) {
+				return out
+			}
+		case "YEARLY":
+			t := at(y+n, m, d)
+			if t.Month() != m {
+				if !t.Before(to) {
+					return out
+				}
+				continue
+			}
+			if !emit(t) {
+				return out
+			}
+		}
+	}
+}
+
+// whether t is one of the BYDAY weekdays, any day when there are none
+func (rl rule) onDay(t time.Time) bool {
+	if len(rl.byDay) == 0 {
+		return true
+	}
+	for _, wd := range rl.byDay {
+		if t.Weekday() == wd {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	db "github.com/chee-zer/negentropy/database/sqlc"
+	"github.com/chee-zer/negentropy/ical"
+)
+
+// finished sessions as calendar events, keyed by the task they're filed under.
+// Entropy is filed under the task it was taken from when that's known
+func sessionEvents(sessions []db.Session, tasks map[int64]db.Task, now time.Time) (map[int64][]ical.Event, error) {
+	events := make(map[int64][]ical.Event)
+	for _, s := range sessions {
+		sp, err := spanFromSession(s, now)
+		if err != nil {
+			return nil, err
+		}
+		if sp.Running {
+			continue
+		}
+		name := taskName(tasks, s.TaskID)
+		owner := s.TaskID
+		summary := name
+		switch {
+		case sp.isEntropy():
+			owner = sp.OriginTaskID
+			summary = "Entropy"
+			if s.OriginTaskID.Valid {
+				summary = "Entropy: " + taskName(tasks, sp.OriginTaskID)
+			}
+		case sp.isBreak():
+			summary = "Break: " + name
+		}
+		ev := ical.Event{
+			UID:        fmt.Sprintf("session-%d@negentropy", s.ID),
+			Start:      sp.Start,
+			End:        sp.End,
+			Summary:    summary,
+			Categories: []string{name},
+		}
+		if sp.Reason != "" {
+			ev.Description = "reason: " + sp.Reason
+		}
+		events[owner] = append(events[owner], ev)
+	}
+	return events, nil
+}
+
+func calendarFor(task db.Task, events []ical.Event) ical.Calendar {
+	cal := ical.Calendar{Name: "negentropy: " + task.Name, Events: events}
+	if task.ColorHex.Valid && hexColor.MatchString(task.ColorHex.String) {
+		cal.Color = task.ColorHex.String
+	}
+	return cal
+}
@@ -0,0 +1,141 @@
+package idle
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// reports how long the user has been away from the machine
+type Source interface {
+	Idle() (time.Duration, error)
+}
+
+var ErrUnavailable = errors.New("idle source unavailable")
+
+// picks a source by its config name: "auto", "xprintidle", "logind" or "none".
+// Unknown names fall back to auto
+func FromName(name string) Source {
+	switch name {
+	case "none":
+		return None{}
+	case "xprintidle":
+		return XPrintIdle{}
+	case "logind":
+		return Logind{}
+	}
+	return Any{XPrintIdle{}, Logind{}}
+}
+
+// never idle, used when detection is turned off
+type None struct{}
+
+func (None) Idle() (time.Duration, error) {
+	return 0, nil
+}
+
+// X11 input idle time, needs the xprintidle binary
+type XPrintIdle struct{}
+
+func (XPrintIdle) Idle() (time.Duration, error) {
+	if _, err := exec.LookPath("xprintidle"); err != nil {
+		return 0, ErrUnavailable
+	}
+	out, err := exec.Command("xprintidle").Output()
+	if err != nil {
+		return 0, err
+	}
+	ms, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+// screen lock and idle hint from systemd-logind, works on wayland too
+type Logind struct{}
+
+func (Logind) Idle() (time.Duration, error) {
+	if _, err := exec.LookPath("loginctl"); err != nil {
+		return 0, ErrUnavailable
+	}
+	session := os.Getenv("XDG_SESSION_ID")
+	if session == "" {
+		session = "auto"
+	}
+	out, err := exec.Command("loginctl", "show-session", session,
+		"-p", "LockedHint", "-p", "IdleHint", "-p", "IdleSinceHint").Output()
+	if err != nil {
+		return 0, err
+	}
+	return parseLoginctl(string(out), time.Now())
+}
+
+func parseLoginctl(out string, now time.Time) (time.Duration, error) {
+	props := make(map[string]string)
+	for _, line := range strings.Split(out, "\n") {
+		if k, v, ok := strings.Cut(strings.TrimSpace(line), "="); ok {
+			props[k] = v
+		}
+	}
+	if props["LockedHint"] != "yes" && props["IdleHint"] != "yes" {
+		return 0, nil
+	}
+	// microseconds since the epoch, 0 when logind doesn't know
+	usec, err := strconv.ParseInt(props["IdleSinceHint"], 10, 64)
+	if err != nil || usec == 0 {
+		return 0, ErrUnavailable
+	}
+	return now.Sub(time.UnixMicro(usec)), nil
+}
+
+// asks every source and reports the longest idle time, unavailable sources are skipped
+type Any []Source
+
+func (a Any) Idle() (time.Duration, error) {
+	var longest time.Duration
+	var lastErr error = ErrUnavailable
+	ok := false
+	for _, s := range a {
+		d, err := s.Idle()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		ok = true
+		longest = max(longest, d)
+	}
+	if !ok {
+		return 0, lastErr
+	}
+	return longest, nil
+}
+
+// in-memory source for tests and manual testing, safe to Set from another goroutine
+type Fake struct {
+	mu   sync.Mutex
+	idle time.Duration
+	err  error
+}
+
+func (f *Fake) Set(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.idle = d
+}
+
+func (f *Fake) SetErr(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.err = err
+}
+
+func (f *Fake) Idle() (time.Duration, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.idle, f.err
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/chee-zer/negentropy/daemon"
+	db "github.com/chee-zer/negentropy/database/sqlc"
+)
+
+// how often the idle source is polled while a session runs
+const idleCheckInterval = 15 * time.Second
+
+type idleCheckMsg struct {
+	sessionID int64
+	at        time.Time
+}
+
+type idleChoice int
+
+const (
+	idleKeep idleChoice = iota
+	idleDiscard
+	idleEntropy
+	idleBreak
+)
+
+// schedules the next idle check for the current session, a check for an older session is dropped
+func (m model) idleCheckCmd() tea.Cmd {
+	if m.idleThreshold <= 0 || m.CurrentSession == nil {
+		return nil
+	}
+	id := m.CurrentSession.ID
+	return tea.Tick(idleCheckInterval, func(t time.Time) tea.Msg {
+		return idleCheckMsg{sessionID: id, at: t}
+	})
+}
+
+func (m model) checkIdle(msg idleCheckMsg) (tea.Model, tea.Cmd) {
+	if m.CurrentSession == nil || msg.sessionID != m.CurrentSession.ID || !m.Timer.Running {
+		return m, nil
+	}
+	gap := msg.at.Sub(m.lastIdleCheck)
+	m.lastIdleCheck = msg.at
+	if m.state == IdleReturned {
+		return m, m.idleCheckCmd()
+	}
+
+	// ticks don't fire while the machine sleeps (lid closed), so a big gap between checks is idle
+	// time the source itself can't report
+	if gap > 3*idleCheckInterval && gap >= m.idleThreshold {
+		if m.idleSince.IsZero() {
+			m.idleSince = msg.at.Add(-gap)
+		}
+		m.idleUntil = msg.at
+		m.idleSlept = true
+		return m.promptIdle(), m.idleCheckCmd()
+	}
+
+	idleFor, err := m.idle.Idle()
+	if err != nil {
+		log.Printf("idle check: %v", err)
+		return m, m.idleCheckCmd()
+	}
+	switch {
+	case idleFor >= m.idleThreshold && m.idleSince.IsZero():
+		m.idleSince = msg.at.Add(-idleFor)
+	case idleFor < m.idleThreshold && !m.idleSince.IsZero():
+		m.idleUntil = msg.at.Add(-idleFor)
+		m = m.promptIdle()
+	}
+	return m, m.idleCheckCmd()
+}
+
+// asks what to do with the idle span, only once the user isn't in the middle of something else
+func (m model) promptIdle() model {
+	if m.state != TimerRunning {
+		return m
+	}
+	m.state = IdleReturned
+	m.StatusQuote = fmt.Sprintf("You were away %s (%s - %s). k: keep  d: discard  e: entropy  b: break",
+		formatDuration(m.idleUntil.Sub(m.idleSince)), m.idleSince.Format("15:04"), m.idleUntil.Format("15:04"))
+	return m
+}
+
+func (m model) updateIdleReturned(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	var choice idleChoice
+	switch msg.String() {
+	case "k":
+		choice = idleKeep
+	case "d":
+		choice = idleDiscard
+	case "e":
+		choice = idleEntropy
+	case "b":
+		choice = idleBreak
+	default:
+		return m, nil
+	}
+	since, until, slept := m.idleSince, m.idleUntil, m.idleSlept
+	m.idleSince, m.idleUntil, m.idleSlept = time.Time{}, time.Time{}, false
+	m.state = TimerRunning
+	if choice == idleKeep {
+		// the stopwatch doesn't tick while the machine sleeps
+		if slept {
+			m.Timer.SessionTime += until.Sub(since)
+		}
+		m.StatusQuote = "Kept idle time in the session"
+		return m, nil
+	}
+	m, err := m.splitSession(since, until, choice)
+	if err != nil {
+		m.StatusQuote = "Couldn't split session: " + err.Error()
+		return m, nil
+	}
+	if !slept {
+		m.Timer.SessionTime = max(0, m.Timer.SessionTime-until.Sub(since))
+	}
+	m.StatusQuote = "Session resumed, idle time removed"
+	return m, m.idleCheckCmd()
+}
+
+// ends the running session where the idle span started, stores the span according to choice
+// and continues with a new session for the same task from where the user came back
+func (m model) splitSession(since, until time.Time, choice idleChoice) (model, error) {
+	// hooks and webhooks see the stop and start, marked so they can tell it from the user's
+	ctx := daemon.WithCause(context.Background(), causeIdle)
+	taskID := m.ActiveTaskId
+	if _, err := m.timer.Stop(ctx, since); err != nil {
+		return m, err
+	}
+
+	var err error
+	idleSpan := db.InsertSessionParams{
+		StartTime: formatTimestamp(since),
+		EndTime:   sql.NullString{String: formatTimestamp(until), Valid: true},
+		TaskID:    taskID,
+		Kind:      sessionWork,
+	}
+	switch choice {
+	case idleEntropy:
+		idleSpan.TaskID = entropyTaskID
+		idleSpan.OriginTaskID = sql.NullInt64{Int64: taskID, Valid: true}
+		idleSpan.EntropyReason = sql.NullString{String: "idle", Valid: true}
+		_, err = m.db.InsertSession(ctx, idleSpan)
+	case idleBreak:
+		idleSpan.Kind = sessionBreak
+		_, err = m.db.InsertSession(ctx, idleSpan)
+	}
+	if err != nil {
+		return m, err
+	}
+
+	status, err := m.timer.Start(ctx, taskID, until)
+	// the session was briefly stopped, a sync that saw that in between is stale
+	m.timerChangedAt = time.Now()
+	if err != nil {
+		return m, err
+	}
+	m.CurrentSession = status.Session
+	return m.refreshToday(), nil
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	db "github.com/chee-zer/negentropy/database/sqlc"
+	"github.com/chee-zer/negentropy/idle"
+	"github.com/chee-zer/negentropy/stopwatch"
+)
+
+// a model with task "code" running since start, on a fresh database
+func runningModel(t *testing.T, start time.Time) (model, *db.Queries) {
+	t.Helper()
+	_, q := openTestDB(t)
+	ctx := context.Background()
+	task, err := q.CreateTask(ctx, db.CreateTaskParams{Name: "code"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tasks, err := q.GetTasks(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	taskMap := make(map[int64]db.Task)
+	for _, t := range tasks {
+		taskMap[t.ID] = t
+	}
+	events := &dispatcher{}
+	m := model{
+		db:     q,
+		tasks:  taskMap,
+		timer:  localTimer{q: q, events: events},
+		tabs:   NewTabModel(tasks),
+		Timer:  stopwatch.NewTimer("dummy"),
+		idle:   &idle.Fake{},
+		events: events,
+	}
+	m = m.selectTask(task.ID)
+	status, err := m.timer.Start(ctx, task.ID, start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m.resume(*status.Session), q
+}
+
+func sessionsOf(t *testing.T, q *db.Queries) []db.Session {
+	t.Helper()
+	sessions, err := q.GetSessionsInRange(context.Background(), db.GetSessionsInRangeParams{RangeStart: "", RangeEnd: "9999"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sessions
+}
+
+func TestCheckIdle(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	threshold := 5 * time.Minute
+	tests := []struct {
+		name      string
+		since     time.Time // idleSince before the check
+		lastCheck time.Time
+		idleFor   time.Duration
+		idleErr   error
+		state     appState
+		wantSince time.Time
+		wantUntil time.Time
+		wantSlept bool
+	}{
+		{
+			name:      "active",
+			lastCheck: now.Add(-idleCheckInterval),
+			idleFor:   time.Minute,
+			state:     TimerRunning,
+		},
+		{
+			name:      "goes idle",
+			lastCheck: now.Add(-idleCheckInterval),
+			idleFor:   6 * time.Minute,
+			state:     TimerRunning,
+			wantSince: now.Add(-6 * time.Minute),
+		},
+		{
+			name:      "still idle",
+			since:     now.Add(-20 * time.Minute),
+			lastCheck: now.Add(-idleCheckInterval),
+			idleFor:   20 * time.Minute,
+			state:     TimerRunning,
+			wantSince: now.Add(-20 * time.Minute),
+		},
+		{
+			name:      "comes back",
+			since:     now.Add(-20 * time.Minute),
+			lastCheck: now.Add(-idleCheckInterval),
+			idleFor:   10 * time.Second,
+			state:     IdleReturned,
+			wantSince: now.Add(-20 * time.Minute),
+			wantUntil: now.Add(-10 * time.Second),
+		},
+		{
+			name:      "source fails",
+			lastCheck: now.Add(-idleCheckInterval),
+			idleErr:   idle.ErrUnavailable,
+			state:     TimerRunning,
+		},
+		{
+			name:      "machine slept",
+			lastCheck: now.Add(-time.Hour),
+			state:     IdleReturned,
+			wantSince: now.Add(-time.Hour),
+			wantUntil: now,
+			wantSlept: true,
+		},
+		{
+			name:      "short sleep",
+			lastCheck: now.Add(-4 * idleCheckInterval),
+			state:     TimerRunning,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, _ := runningModel(t, now.Add(-2*time.Hour))
+			m.idleThreshold = threshold
+			m.idleSince = tt.since
+			m.lastIdleCheck = tt.lastCheck
+			src := &idle.Fake{}
+			src.Set(tt.idleFor)
+			src.SetErr(tt.idleErr)
+			m.idle = src
+
+			next, cmd := m.checkIdle(idleCheckMsg{sessionID: m.CurrentSession.ID, at: now})
+			got := next.(model)
+			if got.state != tt.state {
+				t.Errorf("state = %v, want %v", got.state, tt.state)
+			}
+			if !got.idleSince.Equal(tt.wantSince) || !got.idleUntil.Equal(tt.wantUntil) || got.idleSlept != tt.wantSlept {
+				t.Errorf("idle %v - %v slept %v, want %v - %v slept %v",
+					got.idleSince, got.idleUntil, got.idleSlept, tt.wantSince, tt.wantUntil, tt.wantSlept)
+			}
+			if !got.lastIdleCheck.Equal(now) {
+				t.Errorf("lastIdleCheck = %v, want %v", got.lastIdleCheck, now)
+			}
+			if cmd == nil {
+				t.Error("next check wasn't scheduled")
+			}
+		})
+	}
+}
+
+func TestCheckIdleStaleSession(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	m, _ := runningModel(t, now.Add(-time.Hour))
+	m.idleThreshold = 5 * time.Minute
+	m.lastIdleCheck = now.Add(-time.Hour)
+
+	next, cmd := m.checkIdle(idleCheckMsg{sessionID: m.CurrentSession.ID + 1, at: now})
+	got := next.(model)
+	if cmd != nil || got.state != TimerRunning || !got.idleSince.IsZero() {
+		t.Errorf("check for another session changed the model: state %v, idle since %v", got.state, got.idleSince)
+	}
+}
+
+func TestIdleReturned(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	start := now.Add(-2 * time.Hour)
+	since := now.Add(-time.Hour)
+	until := now.Add(-30 * time.Minute)
+	elapsed := 2 * time.Hour
+	type row struct {
+		start, end time.Time // zero end for the running one
+		task       bool      // on the task, otherwise entropy
+		kind       string
+		reason     string
+	}
+	tests := []struct {
+		name        string
+		key         string
+		slept       bool
+		want        []row
+		wantElapsed time.Duration
+	}{
+		{
+			name:        "keep",
+			key:         "k",
+			want:        []row{{start: start, task: true, kind: sessionWork}},
+			wantElapsed: elapsed,
+		},
+		{
+			name:        "keep after sleeping",
+			key:         "k",
+			slept:       true,
+			want:        []row{{start: start, task: true, kind: sessionWork}},
+			wantElapsed: elapsed + 30*time.Minute,
+		},
+		{
+			name: "discard",
+			key:  "d",
+			want: []row{
+				{start: start, end: since, task: true, kind: sessionWork},
+				{start: until, task: true, kind: sessionWork},
+			},
+			wantElapsed: elapsed - 30*time.Minute,
+		},
+		{
+			name:  "discard after sleeping",
+			key:   "d",
+			slept: true,
+			want: []row{
+				{start: start, end: since, task: true, kind: sessionWork},
+				{start: until, task: true, kind: sessionWork},
+			},
+			// the stopwatch didn't count the sleep in the first place
+			wantElapsed: elapsed,
+		},
+		{
+			name: "entropy",
+			key:  "e",
+			want: []row{
+				{start: start, end: since, task: true, kind: sessionWork},
+				{start: since, end: until, kind: sessionWork, reason: "idle"},
+				{start: until, task: true, kind: sessionWork},
+			},
+			wantElapsed: elapsed - 30*time.Minute,
+		},
+		{
+			name: "break",
+			key:  "b",
+			want: []row{
+				{start: start, end: since, task: true, kind: sessionWork},
+				{start: since, end: until, task: true, kind: sessionBreak},
+				{start: until, task: true, kind: sessionWork},
+			},
+			wantElapsed: elapsed - 30*time.Minute,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, q := runningModel(t, start)
+			taskID := m.ActiveTaskId
+			m.Timer.SessionTime = elapsed
+			m.state = IdleReturned
+			m.idleSince, m.idleUntil, m.idleSlept = since, until, tt.slept
+
+			next, _ := m.updateIdleReturned(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(tt.key)})
+			got := next.(model)
+			if got.state != TimerRunning {
+				t.Errorf("state = %v, want running", got.state)
+			}
+			if !got.idleSince.IsZero() || !got.idleUntil.IsZero() || got.idleSlept {
+				t.Error("idle span wasn't cleared")
+			}
+			if got.Timer.SessionTime != tt.wantElapsed {
+				t.Errorf("session time = %v, want %v", got.Timer.SessionTime, tt.wantElapsed)
+			}
+
+			sessions := sessionsOf(t, q)
+			if len(sessions) != len(tt.want) {
+				t.Fatalf("%d sessions, want %d: %+v", len(sessions), len(tt.want), sessions)
+			}
+			for i, w := range tt.want {
+				s := sessions[i]
+				wantTask, wantOrigin := taskID, sql.NullInt64{}
+				if !w.task {
+					wantTask, wantOrigin = entropyTaskID, sql.NullInt64{Int64: taskID, Valid: true}
+				}
+				wantEnd := sql.NullString{}
+				if !w.end.IsZero() {
+					wantEnd = sql.NullString{String: formatTimestamp(w.end), Valid: true}
+				}
+				if s.StartTime != formatTimestamp(w.start) || s.EndTime != wantEnd || s.TaskID != wantTask ||
+					s.OriginTaskID != wantOrigin || s.Kind != w.kind || s.EntropyReason.String != w.reason {
+					t.Errorf("session %d = %+v, want %+v", i, s, w)
+				}
+			}
+			running := sessions[len(sessions)-1]
+			if got.CurrentSession == nil || got.CurrentSession.ID != running.ID {
+				t.Errorf("current session = %+v, want %d", got.CurrentSession, running.ID)
+			}
+		})
+	}
+}
+
+func TestIdleReturnedOtherKey(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	m, q := runningModel(t, now.Add(-time.Hour))
+	m.state = IdleReturned
+	m.idleSince, m.idleUntil = now.Add(-30*time.Minute), now.Add(-10*time.Minute)
+
+	next, _ := m.updateIdleReturned(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("x")})
+	if got := next.(model); got.state != IdleReturned || got.idleSince.IsZero() {
+		t.Error("an unrelated key answered the prompt")
+	}
+	if n := len(sessionsOf(t, q)); n != 1 {
+		t.Errorf("%d sessions, want 1", n)
+	}
+}
+
+func TestSplitSessionEvents(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	m, _ := runningModel(t, now.Add(-time.Hour))
+	rec := &eventRecorder{}
+	m.events.subscribe(rec)
+	m.state = IdleReturned
+	m.idleSince, m.idleUntil = now.Add(-30*time.Minute), now.Add(-10*time.Minute)
+
+	next, _ := m.updateIdleReturned(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("d")})
+	if got := next.(model); got.state != TimerRunning {
+		t.Fatalf("state = %v: %s", got.state, got.StatusQuote)
+	}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if len(rec.events) != 2 {
+		t.Fatalf("events = %+v", rec.events)
+	}
+	for i, name := range []string{eventSessionStop, eventSessionStart} {
+		if ev := rec.events[i]; ev.Name != name || ev.Cause != causeIdle {
+			t.Errorf("event %d = %s cause %q, want %s cause %q", i, ev.Name, ev.Cause, name, causeIdle)
+		}
+	}
+	env := hookEnv(rec.events[0])
+	if !slices.Contains(env, "NEGENTROPY_CAUSE=idle") {
+		t.Errorf("hook env = %v", env)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"strings"
+
+	db "github.com/chee-zer/negentropy/database/sqlc"
+	"github.com/chee-zer/negentropy/importer"
+)
+
+// entries without a project end up here
+const unsortedProject = "imported"
+
+type importStats struct {
+	Imported     int
+	Duplicates   int
+	Skipped      int
+	CreatedTasks []string
+	// ids of the created tasks, empty on a dry run
+	CreatedIDs []int64
+}
+
+// maps projects to tasks by name, creating missing ones, and inserts every entry that isn't
+// already there (same task and start time). With dryRun nothing is written
+func importEntries(ctx context.Context, q db.Querier, entries []importer.Entry, dryRun bool, log io.Writer) (importStats, error) {
+	var stats importStats
+	tasks, err := q.GetTasks(ctx)
+	if err != nil {
+		return stats, err
+	}
+	byName := make(map[string]int64)
+	for _, t := range tasks {
+		byName[strings.ToLower(t.Name)] = t.ID
+	}
+	// fake ids for tasks a dry run would create, so their entries aren't looked up in the db
+	planned := int64(-1)
+
+	for _, e := range entries {
+		if !e.End.After(e.Start) {
+			stats.Skipped++
+			continue
+		}
+		project := strings.TrimSpace(e.Project)
+		if project == "" {
+			project = unsortedProject
+		}
+		taskID, ok := byName[strings.ToLower(project)]
+		if !ok {
+			if dryRun {
+				taskID = planned
+				planned--
+			} else {
+				task, err := q.CreateTask(ctx, db.CreateTaskParams{Name: project})
+				if err != nil {
+					return stats, fmt.Errorf("creating task %q: %w", project, err)
+				}
+				taskID = task.ID
+				stats.CreatedIDs = append(stats.CreatedIDs, task.ID)
+			}
+			byName[strings.ToLower(project)] = taskID
+			stats.CreatedTasks = append(stats.CreatedTasks, project)
+		}
+
+		start := formatTimestamp(e.Start)
+		if taskID > 0 {
+			n, err := q.CountSessionsAt(ctx, db.CountSessionsAtParams{TaskID: taskID, StartTime: start})
+			if err != nil {
+				return stats, err
+			}
+			if n > 0 {
+				stats.Duplicates++
+				continue
+			}
+		}
+		if dryRun {
+			fmt.Fprintf(log, "  %s  %-20s %s\n", e.Start.In(dayLocation).Format("2006-01-02 15:04"), project, formatDuration(e.End.Sub(e.Start)))
+			stats.Imported++
+			continue
+		}
+		_, err := q.InsertSession(ctx, db.InsertSessionParams{
+			StartTime: start,
+			EndTime:   sql.NullString{String: formatTimestamp(e.End), Valid: true},
+			TaskID:    taskID,
+			Kind:      sessionWork,
+		})
+		if err != nil {
+			return stats, err
+		}
+		stats.Imported++
+	}
+	return stats, nil
+}
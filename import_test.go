@@ -0,0 +1,45 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestImportEmitsTaskCreate(t *testing.T) {
+	sqldb, q := openTestDB(t)
+	events := &dispatcher{}
+	rec := &eventRecorder{}
+	events.subscribe(rec)
+	a := app{sqldb: sqldb, queries: q, events: events}
+	path := filepath.Join(t.TempDir(), "clockify.csv")
+	csv := "Project,Description,Start Date,Start Time,End Date,End Time\n" +
+		"web,,2025-03-04,09:00,2025-03-04,10:00\n" +
+		"web,,2025-03-05,09:00,2025-03-05,10:00\n" +
+		"docs,,2025-03-05,11:00,2025-03-05,12:00\n"
+	if err := os.WriteFile(path, []byte(csv), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := runImport(a, []string{"--format", "clockify", "--dry-run", path}); err != nil {
+		t.Fatal(err)
+	}
+	if got := rec.names(); len(got) != 0 {
+		t.Errorf("dry run raised %v", got)
+	}
+	if err := runImport(a, []string{"--format", "clockify", path}); err != nil {
+		t.Fatal(err)
+	}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	var names []string
+	for _, ev := range rec.events {
+		if ev.Name != eventTaskCreate || ev.Task == nil {
+			t.Fatalf("unexpected event %+v", ev)
+		}
+		names = append(names, ev.Task.Name)
+	}
+	if len(names) != 2 || names[0] != "web" || names[1] != "docs" {
+		t.Errorf("task_create for %v, want web and docs", names)
+	}
+}
@@ -0,0 +1,220 @@
+package importer
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// one finished time entry from another tracker
+type Entry struct {
+	Project     string
+	Description string
+	Start       time.Time
+	End         time.Time
+}
+
+// parses an export in the named format: toggl, clockify or timewarrior.
+// CSV times carry no zone and are read in loc. dateFormat is one of DateFormats, empty
+// finds the one the whole file uses
+func Parse(format string, r io.Reader, loc *time.Location, dateFormat string) ([]Entry, error) {
+	switch format {
+	case "toggl":
+		return Toggl(r, loc, dateFormat)
+	case "clockify":
+		return Clockify(r, loc, dateFormat)
+	case "timewarrior":
+		return Timewarrior(r)
+	}
+	return nil, fmt.Errorf("unknown import format %q, use toggl, clockify or timewarrior", format)
+}
+
+// both trackers name the columns the same way, only the capitalization differs
+var reportColumns = csvColumns{
+	project:     "project",
+	description: "description",
+	startDate:   "start date",
+	startTime:   "start time",
+	endDate:     "end date",
+	endTime:     "end time",
+}
+
+// Toggl Track detailed report CSV
+func Toggl(r io.Reader, loc *time.Location, dateFormat string) ([]Entry, error) {
+	return parseCSV(r, loc, dateFormat, reportColumns)
+}
+
+// Clockify detailed report CSV
+func Clockify(r io.Reader, loc *time.Location, dateFormat string) ([]Entry, error) {
+	return parseCSV(r, loc, dateFormat, reportColumns)
+}
+
+type csvColumns struct {
+	project, description                   string
+	startDate, startTime, endDate, endTime string
+}
+
+// date layouts seen in toggl and clockify exports, by the name dateFormat takes. Clockify
+// follows the account's locale settings, so a file can use any of them
+var DateFormats = []string{"YYYY-MM-DD", "MM/DD/YYYY", "DD/MM/YYYY", "DD.MM.YYYY"}
+
+var dateLayouts = map[string]string{
+	"YYYY-MM-DD": "2006-01-02",
+	"MM/DD/YYYY": "01/02/2006",
+	"DD/MM/YYYY": "02/01/2006",
+	"DD.MM.YYYY": "02.01.2006",
+}
+
+var timeLayouts = []string{"15:04:05", "15:04", "03:04:05 PM", "03:04 PM", "3:04:05 PM", "3:04 PM"}
+
+// the date format every date fits. It's decided for the whole file and not per row, with
+// 03/04/2025 alone there's no telling march from april
+func detectDateFormat(dates []string) (string, error) {
+	var fits []string
+	for _, name := range DateFormats {
+		ok := true
+		for _, d := range dates {
+			if _, err := time.Parse(dateLayouts[name], d); err != nil {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			fits = append(fits, name)
+		}
+	}
+	switch len(fits) {
+	case 0:
+		return "", fmt.Errorf("the dates don't all fit one of %s", strings.Join(DateFormats, ", "))
+	case 1:
+		return fits[0], nil
+	}
+	return "", fmt.Errorf("the dates could be %s, pick one with --date-format", strings.Join(fits, " or "))
+}
+
+func parseDateTime(date, clock, dateLayout string, loc *time.Location) (time.Time, error) {
+	for _, tl := range timeLayouts {
+		if t, err := time.ParseInLocation(dateLayout+" "+tl, date+" "+clock, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("can't read date %q %q", date, clock)
+}
+
+func parseCSV(r io.Reader, loc *time.Location, dateFormat string, cols csvColumns) ([]Entry, error) {
+	if dateFormat != "" && dateLayouts[dateFormat] == "" {
+		return nil, fmt.Errorf("unknown date format %q, use %s", dateFormat, strings.Join(DateFormats, ", "))
+	}
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+	index := make(map[string]int)
+	for i, h := range header {
+		h = strings.TrimPrefix(h, "\ufeff")
+		index[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	for _, name := range []string{cols.project, cols.startDate, cols.startTime, cols.endDate, cols.endTime} {
+		if _, ok := index[name]; !ok {
+			return nil, fmt.Errorf("missing column %q", name)
+		}
+	}
+	field := func(rec []string, name string) string {
+		i, ok := index[name]
+		if !ok || i >= len(rec) {
+			return ""
+		}
+		return strings.TrimSpace(rec[i])
+	}
+
+	// the whole file is read first, the date format depends on every row
+	var records [][]string
+	var dates []string
+	for line := 2; ; line++ {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		records = append(records, rec)
+		dates = append(dates, field(rec, cols.startDate), field(rec, cols.endDate))
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	if dateFormat == "" {
+		if dateFormat, err = detectDateFormat(dates); err != nil {
+			return nil, err
+		}
+	}
+	layout := dateLayouts[dateFormat]
+
+	entries := make([]Entry, 0, len(records))
+	for i, rec := range records {
+		line := i + 2
+		start, err := parseDateTime(field(rec, cols.startDate), field(rec, cols.startTime), layout, loc)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		end, err := parseDateTime(field(rec, cols.endDate), field(rec, cols.endTime), layout, loc)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		entries = append(entries, Entry{
+			Project:     field(rec, cols.project),
+			Description: field(rec, cols.description),
+			Start:       start,
+			End:         end,
+		})
+	}
+	return entries, nil
+}
+
+type timewarriorInterval struct {
+	Start      string   `json:"start"`
+	End        string   `json:"end"`
+	Tags       []string `json:"tags"`
+	Annotation string   `json:"annotation"`
+}
+
+// `timew export` JSON. The first tag is used as the project, the open interval is skipped
+func Timewarrior(r io.Reader) ([]Entry, error) {
+	var intervals []timewarriorInterval
+	if err := json.NewDecoder(r).Decode(&intervals); err != nil {
+		return nil, err
+	}
+	const layout = "20060102T150405Z"
+	var entries []Entry
+	for i, iv := range intervals {
+		if iv.End == "" {
+			continue
+		}
+		start, err := time.Parse(layout, iv.Start)
+		if err != nil {
+			return nil, fmt.Errorf("interval %d: %w", i, err)
+		}
+		end, err := time.Parse(layout, iv.End)
+		if err != nil {
+			return nil, fmt.Errorf("interval %d: %w", i, err)
+		}
+		project := ""
+		if len(iv.Tags) > 0 {
+			project = iv.Tags[0]
+		}
+		entries = append(entries, Entry{
+			Project:     project,
+			Description: iv.Annotation,
+			Start:       start,
+			End:         end,
+		})
+	}
+	return entries, nil
+}
@@ -0,0 +1,96 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const clockifyHeader = "Project,Description,Start Date,Start Time,End Date,End Time\n"
+
+func TestClockifyDates(t *testing.T) {
+	tests := []struct {
+		name       string
+		rows       string
+		dateFormat string
+		wantStart  []string // 2006-01-02 15:04
+		wantErr    string
+	}{
+		{
+			name:      "iso",
+			rows:      "a,,2025-03-04,09:00,2025-03-04,10:00\n",
+			wantStart: []string{"2025-03-04 09:00"},
+		},
+		{
+			name:      "day over 12 decides for the whole file",
+			rows:      "a,,03/04/2025,09:00,03/04/2025,10:00\nb,,25/04/2025,9:00 AM,25/04/2025,10:00 AM\n",
+			wantStart: []string{"2025-04-03 09:00", "2025-04-25 09:00"},
+		},
+		{
+			name:      "month first",
+			rows:      "a,,03/04/2025,09:00,03/04/2025,10:00\nb,,04/25/2025,09:00,04/25/2025,10:00\n",
+			wantStart: []string{"2025-03-04 09:00", "2025-04-25 09:00"},
+		},
+		{
+			name:    "ambiguous",
+			rows:    "a,,03/04/2025,09:00,03/04/2025,10:00\nb,,05/06/2025,09:00,05/06/2025,10:00\n",
+			wantErr: "MM/DD/YYYY or DD/MM/YYYY",
+		},
+		{
+			name:       "ambiguous with a format",
+			rows:       "a,,03/04/2025,09:00,03/04/2025,10:00\n",
+			dateFormat: "DD/MM/YYYY",
+			wantStart:  []string{"2025-04-03 09:00"},
+		},
+		{
+			name:    "mixed formats",
+			rows:    "a,,25/04/2025,09:00,25/04/2025,10:00\nb,,04/26/2025,09:00,04/26/2025,10:00\n",
+			wantErr: "don't all fit",
+		},
+		{
+			name:       "format doesn't fit",
+			rows:       "a,,25/04/2025,09:00,25/04/2025,10:00\n",
+			dateFormat: "MM/DD/YYYY",
+			wantErr:    "line 2",
+		},
+		{
+			name:       "unknown format",
+			rows:       "a,,2025-03-04,09:00,2025-03-04,10:00\n",
+			dateFormat: "YYYY/MM/DD",
+			wantErr:    "unknown date format",
+		},
+		{
+			name:      "dots",
+			rows:      "a,,03.04.2025,23:30,04.04.2025,00:30\n",
+			wantStart: []string{"2025-04-03 23:30"},
+		},
+		{
+			name: "no rows",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := Clockify(strings.NewReader(clockifyHeader+tt.rows), time.UTC, tt.dateFormat)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != len(tt.wantStart) {
+				t.Fatalf("%d entries, want %d", len(entries), len(tt.wantStart))
+			}
+			for i, e := range entries {
+				if got := e.Start.Format("2006-01-02 15:04"); got != tt.wantStart[i] {
+					t.Errorf("entry %d starts %s, want %s", i, got, tt.wantStart[i])
+				}
+				if e.End.Sub(e.Start) != time.Hour {
+					t.Errorf("entry %d lasts %v, want 1h", i, e.End.Sub(e.Start))
+				}
+			}
+		})
+	}
+}
@@ -3,16 +3,23 @@ package main
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/chee-zer/negentropy/daemon"
 	db "github.com/chee-zer/negentropy/database/sqlc"
+	"github.com/chee-zer/negentropy/gitlog"
+	"github.com/chee-zer/negentropy/idle"
+	"github.com/chee-zer/negentropy/notify"
 	"github.com/chee-zer/negentropy/stopwatch"
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -28,10 +35,52 @@ type model struct {
 	Timer          stopwatch.StopwatchModel
 	quitting       bool
 	CurrentSession *db.Session
-	textInput      textinput.Model
-	keymap         keymap
-	state          appState
-	pendingAction  currentAction
+	// the daemon when attached, otherwise the database, see timer.go
+	timer          daemon.Timer
+	attached       bool
+	timerChangedAt time.Time
+	// hooks and other subscribers, see events.go
+	events        *dispatcher
+	textInput     textinput.Model
+	keymap        keymap
+	state         appState
+	pendingAction currentAction
+	// when the user confirmed a reset, the entropy session ends here and not when the reason is picked
+	resetAt time.Time
+	// idle detection, see idlecheck.go
+	idle          idle.Source
+	idleThreshold time.Duration
+	lastIdleCheck time.Time
+	idleSince     time.Time
+	idleUntil     time.Time
+	idleSlept     bool
+	// daily cap from MaxProductivityHours, see cap.go
+	today           todayStats
+	productivityCap time.Duration
+	capWarnBefore   time.Duration
+	enforceCap      bool
+	capWarned       bool
+	capReached      bool
+	progress        progress
+	plannedToday    []plannedBlock
+	// desktop notifications and terminal alerts, see milestones.go and alerts.go
+	notifier    notify.Notifier
+	pomodoro    time.Duration
+	longSession time.Duration
+	milestones  milestoneState
+	alerts      terminalAlerts
+	// full screen views other than the timer
+	view        viewMode
+	heatmap     string
+	heatmapEnd  time.Time
+	heatmapMode heatmapMode
+	timeline    string
+	timelineDay time.Time
+	// commits shown in the timeline, see commits.go
+	gitRepos        []gitRepo
+	gitAuthor       string
+	timelineCommits []gitlog.Commit
+	timelineLoading bool
 }
 type keymap struct {
 	StartStopTimer key.Binding
@@ -41,6 +90,8 @@ type keymap struct {
 	DeleteTask     key.Binding
 	CreateTask     key.Binding
 	ResetTimer     key.Binding
+	ToggleHeatmap  key.Binding
+	ToggleTimeline key.Binding
 	Yes            key.Binding
 	No             key.Binding
 }
@@ -52,6 +103,16 @@ const (
 	TimerRunning
 	Typing
 	Confirming
+	PickingReason
+	IdleReturned
+)
+
+type viewMode int
+
+const (
+	mainView viewMode = iota
+	heatmapView
+	timelineView
 )
 
 type currentAction int
@@ -60,9 +121,10 @@ const (
 	null currentAction = iota
 	deleteTask
 	resetTimer
+	typingReason
 )
 
-func NewModel(queries *db.Queries, cfg UserConfig, errs error) model {
+func NewModel(queries *db.Queries, cfg UserConfig, errs error, timer daemon.Timer, attached bool, events *dispatcher) model {
 	taskMap, tasks, err := GetTaskMap(queries)
 	if err != nil {
 		log.Fatalf("couldn't not load tasks: %v", err)
@@ -78,39 +140,94 @@ func NewModel(queries *db.Queries, cfg UserConfig, errs error) model {
 	ti.CharLimit = 20
 	ti.Width = 20
 
-	activeId := int64(0)
-	if len(tasks) >= 1 {
-		activeId = 1
-	}
-
 	tabs := NewTabModel(tasks)
-	return model{
+	m := model{
 		db:             queries,
 		tasks:          taskMap,
-		ActiveTaskId:   activeId,
 		StatusQuote:    errorString,
 		help:           "this is help string",
 		quitting:       false,
 		Timer:          dummyTimer,
 		CurrentSession: nil,
+		timer:          timer,
+		attached:       attached,
+		events:         events,
 		textInput:      ti,
 		keymap:         cfg.Keymap,
 		tabs:           tabs,
 		state:          TimerNotRunning,
+		idle:           idle.FromName(cfg.IdleSource),
+		idleThreshold:  time.Duration(cfg.IdleThresholdMinutes) * time.Minute,
+
+		productivityCap: time.Duration(cfg.MaxProductivityHours) * time.Hour,
+		capWarnBefore:   time.Duration(cfg.CapWarningMinutes) * time.Minute,
+		enforceCap:      cfg.EnforceProductivityCap,
+
+		notifier:    notify.FromName(cfg.Notifier),
+		pomodoro:    time.Duration(cfg.PomodoroMinutes) * time.Minute,
+		longSession: time.Duration(cfg.LongSessionMinutes) * time.Minute,
+		// refreshToday fills this in, it's there already in case loading today fails
+		milestones: newMilestoneState(),
+		alerts: terminalAlerts{
+			bell:        cfg.AlertBell,
+			flashTitle:  cfg.AlertFlashTitle,
+			windowTitle: cfg.AlertWindowTitle,
+		},
+		gitRepos:  cfg.GitRepos,
+		gitAuthor: cfg.GitAuthor,
+	}
+	// the first task, unless a running session or the working directory picks another one
+	for _, t := range tasks {
+		if t.ID != entropyTaskID {
+			m = m.selectTask(t.ID)
+			break
+		}
+	}
+	status, err := timer.Status(context.Background())
+	if err != nil {
+		m.StatusQuote = "Couldn't load the timer: " + err.Error()
+	} else if status.Running() {
+		m = m.resume(*status.Session)
+		m.StatusQuote = "Resumed session: " + m.tasks[m.ActiveTaskId].Name
+	} else if dir, err := os.Getwd(); err == nil {
+		if task, why, ok := taskForDir(context.Background(), dir, cfg.ProjectTasks, cfg.GitRepos, tasks); ok {
+			m = m.selectTask(task.ID)
+			if errs == nil {
+				m.StatusQuote = fmt.Sprintf("Picked %s, %s", task.Name, why)
+			}
+		}
 	}
+	return m.refreshToday()
 }
 
 func (m model) Init() tea.Cmd {
-	return nil
+	if m.state == TimerRunning {
+		return tea.Batch(m.Timer.StartCmd(), m.idleCheckCmd(), m.timerSyncCmd())
+	}
+	return m.timerSyncCmd()
 }
 
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case stopwatch.ResetMsg, stopwatch.StartStopMsg, stopwatch.TickMsg:
-		var timerCmd tea.Cmd
+		var timerCmd, notifyCmd, titleCmd tea.Cmd
 		m.Timer, timerCmd = m.Timer.Update(msg)
-		return m, timerCmd
+		if _, ok := msg.(stopwatch.TickMsg); ok {
+			m = m.checkCap(time.Now())
+			m, notifyCmd = m.checkMilestones(time.Now())
+		}
+		m, titleCmd = m.updateTitle(time.Now())
+		return m, tea.Batch(timerCmd, notifyCmd, titleCmd)
+
+	case idleCheckMsg:
+		return m.checkIdle(msg)
+
+	case timerSyncMsg:
+		return m.syncTimer(msg)
+
+	case timelineCommitsMsg:
+		return m.timelineCommitsLoaded(msg)
 
 	case DeleteSelectedTaskMsg:
 		var tabCmd tea.Cmd
@@ -125,6 +242,12 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, tabCmd
 
 	case tea.KeyMsg:
+		switch m.view {
+		case heatmapView:
+			return m.updateHeatmap(msg)
+		case timelineView:
+			return m.updateTimeline(msg)
+		}
 		switch m.state {
 		case TimerNotRunning:
 			return m.updateTimerNotRunning(msg)
@@ -135,6 +258,10 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m.updateTyping(msg)
 		case Confirming:
 			return m.updateConfirming(msg)
+		case PickingReason:
+			return m.updatePickingReason(msg)
+		case IdleReturned:
+			return m.updateIdleReturned(msg)
 		}
 	}
 	return m, nil
@@ -145,7 +272,13 @@ func (m model) View() string {
 	if m.quitting {
 		return "quitting negetropy!"
 	}
-	s := fmt.Sprintf("\n\n\n\ntasks: %s\n\nActive Task ID: %d\n  %s\n\n  %s\n %s\n %s\n", m.tabs.View(), m.ActiveTaskId, m.StatusQuote, m.Timer.View(), m.help, m.textInput.View())
+	switch m.view {
+	case heatmapView:
+		return m.heatmapViewString()
+	case timelineView:
+		return m.timelineViewString()
+	}
+	s := fmt.Sprintf("\n\n\n\ntasks: %s\n\nActive Task ID: %d\n  %s\n\n  %s\n  %s\n  %s\n  %s\n %s\n %s\n", m.tabs.View(), m.ActiveTaskId, m.StatusQuote, m.Timer.View(), m.capView(), m.goalView(), m.planView(), m.help, m.textInput.View())
 	return s
 }
 
@@ -168,11 +301,8 @@ func GetTaskMap(queries *db.Queries) (map[int64]db.Task, []db.Task, error) {
 
 func (m model) StartSession() model {
 	taskID := m.ActiveTaskId
-	sessionParams := db.StartSessionParams{
-		StartTime: time.Now().Format("2006-01-02 15:04:05"),
-		TaskID:    taskID,
-	}
-	session, err := m.db.StartSession(context.Background(), sessionParams)
+	status, err := m.timer.Start(context.Background(), taskID, time.Now())
+	m.timerChangedAt = time.Now()
 	if err != nil {
 		m.StatusQuote = "Couldn't start session: " + err.Error()
 		return m
@@ -180,32 +310,32 @@ func (m model) StartSession() model {
 	timer := stopwatch.NewTimerRunning(m.tasks[taskID].Name)
 	m.Timer = timer
 	m.state = TimerRunning
-	m.CurrentSession = &session
-	return m
+	m.CurrentSession = status.Session
+	m.lastIdleCheck = time.Now()
+	return m.refreshToday()
 }
 
+// a session that was already stopped elsewhere is fine, the timer just stops here too
 func (m model) StopSession() model {
-	taskID := m.ActiveTaskId
-	endTime := time.Now().Format("2006-01-02 15:04:05")
-	endSessionParams := db.EndSessionParams{
-		EndTime: sql.NullString{String: endTime, Valid: true},
-		TaskID:  taskID,
+	if _, err := m.timer.Stop(context.Background(), time.Now()); err != nil && !errors.Is(err, daemon.ErrNotRunning) {
+		log.Printf("stop session: %v", err)
 	}
-	m.db.EndSession(context.Background(), endSessionParams)
+	m.timerChangedAt = time.Now()
 	m.state = TimerNotRunning
-	return m
+	m.CurrentSession = nil
+	return m.refreshToday()
 }
 
-func (m model) ResetSession() model {
-	taskID := m.ActiveTaskId
-	endTime := time.Now().Format("2006-01-02 15:04:0")
-	params := db.EndSessionAsEntropyParams{
-		EndTime: sql.NullString{String: endTime, Valid: true},
-		TaskID:  taskID,
+// ends the running session at m.resetAt and moves it to entropy, empty reason is stored as NULL
+func (m model) ResetSession(reason string) model {
+	if _, err := m.timer.Reset(context.Background(), m.resetAt, reason); err != nil && !errors.Is(err, daemon.ErrNotRunning) {
+		log.Printf("reset session: %v", err)
 	}
-	m.db.EndSessionAsEntropy(context.Background(), params)
+	m.timerChangedAt = time.Now()
 	m.state = TimerNotRunning
-	return m
+	m.CurrentSession = nil
+	m.pendingAction = null
+	return m.refreshToday()
 }
 
 func (m model) updateTimerNotRunning(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
@@ -231,12 +361,20 @@ func (m model) updateTimerNotRunning(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.StatusQuote = "No task selected, press " + createNewHotkey + " to create a new task"
 			return m, nil
 		}
+		if !m.capAllowsStart(time.Now()) {
+			m.StatusQuote = fmt.Sprintf("Daily cap of %s reached, not starting a new session", formatDuration(m.productivityCap))
+			return m, nil
+		}
 		m = m.StartSession()
 		// if timer doesn't start due to db error, will return m, nil
 		if m.state == TimerRunning {
 			m.StatusQuote = "Session Started: " + m.tasks[m.ActiveTaskId].Name
-			return m, m.Timer.StartCmd()
+			return m, tea.Batch(m.Timer.StartCmd(), m.idleCheckCmd())
 		}
+	case key.Matches(msg, m.keymap.ToggleHeatmap):
+		return m.openHeatmap(), nil
+	case key.Matches(msg, m.keymap.ToggleTimeline):
+		return m.openTimeline()
 	case key.Matches(msg, m.keymap.CreateTask):
 		cmd = m.textInput.Focus()
 		m.state = Typing
@@ -253,7 +391,12 @@ func (m model) updateTimerNotRunning(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 func (m model) updateTimerRunning(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	switch {
 	case key.Matches(msg, m.keymap.Exit):
-		m.help = "Please end your session before quitting the app. Press Spacebar/enter to pause the timer"
+		// the daemon keeps timing, quitting just detaches
+		if m.attached {
+			m.quitting = true
+			return m, tea.Quit
+		}
+		m.help = "Please end your session before quitting the app. Press Spacebar/enter to pause the timer, or run `negentropy daemon` to keep timing after quitting"
 		return m, nil
 	case key.Matches(msg, m.keymap.StartStopTimer):
 		m.StatusQuote = "Session ended!!"
@@ -263,6 +406,10 @@ func (m model) updateTimerRunning(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.state = Confirming
 		m.pendingAction = resetTimer
 		return m, nil
+	case key.Matches(msg, m.keymap.ToggleHeatmap):
+		return m.openHeatmap(), nil
+	case key.Matches(msg, m.keymap.ToggleTimeline):
+		return m.openTimeline()
 	}
 	if len(m.tasks) == 0 {
 		m = m.NoTaskView()
@@ -276,6 +423,14 @@ func (m model) updateTyping(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	var cmd tea.Cmd
 	switch msg.Type {
 	case tea.KeyEnter:
+		if m.pendingAction == typingReason {
+			m = m.ResetSession(strings.TrimSpace(m.textInput.Value()))
+			m.textInput.Reset()
+			m.textInput.Blur()
+			m.textInput.Placeholder = "Enter task name"
+			m.StatusQuote = "Added Entropy"
+			return m, nil
+		}
 		newTaskName := m.textInput.Value()
 		taskCreatingParams := db.CreateTaskParams{
 			Name: newTaskName,
@@ -304,13 +459,20 @@ func (m model) updateTyping(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.textInput.Reset()
 		m.textInput.Blur()
 		m.StatusQuote = "Task created"
+		m.events.emit(taskEvent(eventTaskCreate, task, time.Now()))
 		return m, nil
 
 	case tea.KeyEsc:
 		m.textInput.Reset()
+		m.textInput.Blur()
+		if m.pendingAction == typingReason {
+			m.textInput.Placeholder = "Enter task name"
+			m.state = PickingReason
+			m.StatusQuote = reasonPrompt()
+			return m, nil
+		}
 		m.state = TimerNotRunning
 		m.StatusQuote = "Task not created -_-"
-		m.textInput.Blur()
 	}
 
 	m.textInput, cmd = m.textInput.Update(msg)
@@ -320,7 +482,7 @@ func (m model) updateTyping(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 func (m model) updateConfirming(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	switch {
 	case key.Matches(msg, m.keymap.Exit):
-		if !m.Timer.Running {
+		if !m.Timer.Running || m.attached {
 			return m, tea.Quit
 		}
 	case key.Matches(msg, m.keymap.No):
@@ -340,13 +502,16 @@ func (m model) updateConfirming(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			err := m.db.DeleteTask(context.Background(), m.ActiveTaskId)
 			if err != nil {
 				m.StatusQuote = "Couldn't delete task: " + err.Error()
+			} else {
+				m.events.emit(taskEvent(eventTaskDelete, m.tasks[m.ActiveTaskId], time.Now()))
 			}
 			m.StatusQuote = "deleted: " + m.tasks[m.ActiveTaskId].Name
 			m.state = TimerNotRunning
 			return m, m.tabs.DeleteSelectedTaskCmd()
 		case resetTimer:
-			m = m.ResetSession()
-			m.StatusQuote = "Added Entropy"
+			m.resetAt = time.Now()
+			m.state = PickingReason
+			m.StatusQuote = reasonPrompt()
 			return m, m.Timer.StopCmd()
 
 		}
@@ -354,25 +519,115 @@ func (m model) updateConfirming(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+func reasonPrompt() string {
+	options := make([]string, 0, len(entropyReasons)+2)
+	for i, r := range entropyReasons {
+		options = append(options, fmt.Sprintf("%d: %s", i+1, r))
+	}
+	options = append(options, fmt.Sprintf("%d: custom", len(entropyReasons)+1), "esc: skip")
+	return "Why was this session lost? " + strings.Join(options, "  ")
+}
+
+// quick pick after a confirmed reset, the session is only written once a reason (or none) is chosen
+func (m model) updatePickingReason(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if msg.Type == tea.KeyEsc {
+		m = m.ResetSession("")
+		m.StatusQuote = "Added Entropy"
+		return m, nil
+	}
+	choice, err := strconv.Atoi(msg.String())
+	switch {
+	case err != nil || choice < 1 || choice > len(entropyReasons)+1:
+		return m, nil
+	case choice == len(entropyReasons)+1:
+		m.pendingAction = typingReason
+		m.state = Typing
+		m.textInput.Placeholder = "Enter reason"
+		m.StatusQuote = "Type a reason, enter to save, esc to go back"
+		return m, m.textInput.Focus()
+	}
+	m = m.ResetSession(entropyReasons[choice-1])
+	m.StatusQuote = "Added Entropy: " + entropyReasons[choice-1]
+	return m, nil
+}
+
 func main() {
-	f, err := tea.LogToFile("debug.log", "debug")
+	if len(os.Args) > 1 && os.Args[1] == "status" {
+		if err := statusMain(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, "negentropy:", err)
+			os.Exit(1)
+		}
+		return
+	}
+	logFile, err := logPath()
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(logFile), 0o755)
+	}
+	if err != nil {
+		fmt.Println("fatal:", err)
+		os.Exit(1)
+	}
+	f, err := tea.LogToFile(logFile, "debug")
 	if err != nil {
 		fmt.Println("fatal:", err)
 		os.Exit(1)
 	}
+	cfgFile, err := configPath()
+	if err != nil {
+		log.Fatalf("Couldn't find the config: %v", err)
+	}
+	dbFile, err := databasePath()
+	if err != nil {
+		log.Fatalf("Couldn't find the db: %v", err)
+	}
 	// INFO: err here wont terminate the app, infact the app will launch with default keybindings
-	cfg, err := GetConfig("./neg.config.json")
+	cfg, cfgErr := GetConfig(cfgFile)
+	dayLocation, dayStart = cfg.Location, cfg.DayStart
+	if cfg.BackupDir == "" {
+		cfg.BackupDir = filepath.Join(filepath.Dir(dbFile), "backups")
+	}
 
 	defer f.Close()
-	sqlitedb, err := sql.Open("sqlite3", "./database/appdb.sqlite")
+	// sqlite would create an empty one without the tables
+	if _, err := os.Stat(dbFile); err != nil {
+		fmt.Fprintf(os.Stderr, "negentropy: no database at %s, create it with\n\n    goose -dir database/schema sqlite3 %s up\n\nor point NEGENTROPY_DB at an existing one\n", dbFile, dbFile)
+		os.Exit(1)
+	}
+	sqlitedb, err := sql.Open("sqlite3", dbFile)
 	if err != nil {
 		log.Fatalf("Couldn't connect to db: %v", err.Error())
 	}
 	defer sqlitedb.Close()
 
 	queries := db.New(sqlitedb)
+	events := &dispatcher{}
+	events.subscribe(newHookRunner(cfg.Hooks))
+	webhooks := newWebhookSender(queries, cfg.Webhooks)
+	events.subscribe(webhooks)
+	// hooks and webhooks started by a short command still get to finish
+	defer events.wait()
+
+	if len(os.Args) > 1 {
+		if err := runCommand(app{sqldb: sqlitedb, queries: queries, cfg: cfg, events: events, webhooks: webhooks}, os.Args[1:]); err != nil {
+			events.wait()
+			fmt.Fprintln(os.Stderr, "negentropy:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if path, err := rotateDailyBackup(context.Background(), sqlitedb, cfg.BackupDir, cfg.BackupKeepDays, time.Now()); err != nil {
+		log.Printf("daily backup failed: %v", err)
+	} else if path != "" {
+		log.Printf("daily backup written to %s", path)
+	}
+
+	retryCtx, stopRetry := context.WithCancel(context.Background())
+	defer stopRetry()
+	go webhooks.retry(retryCtx)
 
-	p := tea.NewProgram(NewModel(queries, cfg, err))
+	timer, attached := connectTimer(queries, cfg.DaemonSocket, events)
+	p := tea.NewProgram(NewModel(queries, cfg, cfgErr, timer, attached, events))
 
 	if _, err := p.Run(); err != nil {
 		fmt.Printf("could'nt run program: %v", err)
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/chee-zer/negentropy/notify"
+)
+
+// something worth telling the user about while a session runs
+type milestone struct {
+	title string
+	body  string
+}
+
+// counters for the running session, so every milestone is announced once
+type milestoneState struct {
+	sessionID  int64
+	pomodoros  int
+	longSeen   bool
+	targetsMet map[int64]bool
+}
+
+func newMilestoneState() milestoneState {
+	return milestoneState{targetsMet: make(map[int64]bool)}
+}
+
+// checked on every stopwatch tick. A session that is new to us (started, resumed or split)
+// only sets the counters, so resuming a long session doesn't announce everything again
+func (m model) checkMilestones(now time.Time) (model, tea.Cmd) {
+	if m.state != TimerRunning || m.CurrentSession == nil {
+		return m, nil
+	}
+	elapsed := m.Timer.SessionTime
+	fresh := m.milestones.sessionID != m.CurrentSession.ID
+	if fresh {
+		m.milestones.sessionID = m.CurrentSession.ID
+		m.milestones.pomodoros = 0
+		m.milestones.longSeen = false
+	}
+
+	var reached []milestone
+	name := m.tasks[m.ActiveTaskId].Name
+	if m.pomodoro > 0 {
+		n := int(elapsed / m.pomodoro)
+		if n > m.milestones.pomodoros && !fresh {
+			reached = append(reached, milestone{
+				title: "Pomodoro done",
+				body:  fmt.Sprintf("%s: %s focused, take a short break", name, formatDuration(time.Duration(n)*m.pomodoro)),
+			})
+		}
+		m.milestones.pomodoros = n
+	}
+	if m.longSession > 0 && elapsed >= m.longSession && !m.milestones.longSeen {
+		m.milestones.longSeen = true
+		if !fresh {
+			reached = append(reached, milestone{
+				title: "Long session",
+				body:  fmt.Sprintf("%s has been running for %s, still on it?", name, formatDuration(elapsed)),
+			})
+		}
+	}
+	if t, ok := m.tasks[m.ActiveTaskId]; ok && t.DailyTarget.Valid && t.DailyTarget.Int64 > 0 {
+		target := time.Duration(t.DailyTarget.Int64) * time.Second
+		if m.today.task(t.ID, now) >= target && !m.milestones.targetsMet[t.ID] {
+			m.milestones.targetsMet[t.ID] = true
+			m.events.emit(taskEvent(eventTargetReached, t, now))
+			reached = append(reached, milestone{
+				title: "Daily target reached",
+				body:  fmt.Sprintf("%s: %s done today", name, formatDuration(target)),
+			})
+		}
+	}
+	var alertCmd tea.Cmd
+	m, alertCmd = m.alertTerminal(reached, now)
+	return m, tea.Batch(m.announce(reached), alertCmd)
+}
+
+// which daily targets are already met, so only crossing one is announced. Recomputed
+// whenever today's totals are reloaded
+func (m model) markTargetsMet(now time.Time) model {
+	m.milestones.targetsMet = newMilestoneState().targetsMet
+	for _, t := range m.tasks {
+		if t.DailyTarget.Valid && t.DailyTarget.Int64 > 0 && m.today.task(t.ID, now) >= time.Duration(t.DailyTarget.Int64)*time.Second {
+			m.milestones.targetsMet[t.ID] = true
+		}
+	}
+	return m
+}
+
+// sends the desktop notifications off the update loop, notify-send can take a moment
+func (m model) announce(reached []milestone) tea.Cmd {
+	if len(reached) == 0 {
+		return nil
+	}
+	notifier := m.notifier
+	return func() tea.Msg {
+		for _, ms := range reached {
+			if err := notifier.Notify(ms.title, ms.body); err != nil && !errors.Is(err, notify.ErrUnavailable) {
+				log.Printf("notify: %v", err)
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	db "github.com/chee-zer/negentropy/database/sqlc"
+	"github.com/chee-zer/negentropy/notify"
+)
+
+// keeps every event it gets
+type eventRecorder struct {
+	mu     sync.Mutex
+	events []event
+}
+
+func (r *eventRecorder) handle(ev event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, ev)
+}
+
+func (r *eventRecorder) wait() {}
+
+func (r *eventRecorder) names() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var names []string
+	for _, ev := range r.events {
+		names = append(names, ev.Name)
+	}
+	return names
+}
+
+// runs cmd and everything it batches, the messages are dropped
+func runCmd(cmd tea.Cmd) {
+	if cmd == nil {
+		return
+	}
+	if batch, ok := cmd().(tea.BatchMsg); ok {
+		for _, c := range batch {
+			runCmd(c)
+		}
+	}
+}
+
+// the TUI model on a fresh database, resuming a session of task "code" that started at start
+func milestoneModel(t *testing.T, start time.Time, cfg UserConfig) (model, *notify.Fake, *eventRecorder) {
+	t.Helper()
+	_, q := openTestDB(t)
+	ctx := context.Background()
+	task, err := q.CreateTask(ctx, db.CreateTaskParams{Name: "code"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := &dispatcher{}
+	rec := &eventRecorder{}
+	events.subscribe(rec)
+	timer := localTimer{q: q}
+	if _, err := timer.Start(ctx, task.ID, start); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Notifier = "none"
+	m := NewModel(q, cfg, nil, timer, false, events)
+	fake := &notify.Fake{}
+	m.notifier = fake
+	return m, fake, rec
+}
+
+func titles(sent []notify.Notification) []string {
+	var ts []string
+	for _, n := range sent {
+		ts = append(ts, n.Title)
+	}
+	return ts
+}
+
+func TestCheckMilestones(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	// elapsed session time at each tick and the notifications it should send
+	type tick struct {
+		elapsed time.Duration
+		want    []string
+	}
+	tests := []struct {
+		name  string
+		cfg   UserConfig
+		ticks []tick
+	}{
+		{
+			name: "pomodoros",
+			cfg:  UserConfig{PomodoroMinutes: 25},
+			ticks: []tick{
+				{elapsed: time.Minute},
+				{elapsed: 24 * time.Minute},
+				{elapsed: 25 * time.Minute, want: []string{"Pomodoro done"}},
+				{elapsed: 26 * time.Minute},
+				{elapsed: 50 * time.Minute, want: []string{"Pomodoro done"}},
+			},
+		},
+		{
+			name: "long session once",
+			cfg:  UserConfig{LongSessionMinutes: 90},
+			ticks: []tick{
+				{elapsed: time.Minute},
+				{elapsed: 90 * time.Minute, want: []string{"Long session"}},
+				{elapsed: 120 * time.Minute},
+			},
+		},
+		{
+			name: "resumed late",
+			cfg:  UserConfig{PomodoroMinutes: 25, LongSessionMinutes: 90},
+			ticks: []tick{
+				// the first tick of a session only catches up
+				{elapsed: 100 * time.Minute},
+				{elapsed: 101 * time.Minute},
+				{elapsed: 125 * time.Minute, want: []string{"Pomodoro done"}},
+			},
+		},
+		{
+			name:  "turned off",
+			ticks: []tick{{elapsed: time.Minute}, {elapsed: 10 * time.Hour}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, fake, _ := milestoneModel(t, now.Add(-time.Minute), tt.cfg)
+			for i, tk := range tt.ticks {
+				before := len(fake.Sent())
+				m.Timer.SessionTime = tk.elapsed
+				var cmd tea.Cmd
+				m, cmd = m.checkMilestones(now)
+				runCmd(cmd)
+				got := titles(fake.Sent()[before:])
+				if len(got) != len(tk.want) || (len(got) > 0 && got[0] != tk.want[0]) {
+					t.Errorf("tick %d at %v sent %v, want %v", i, tk.elapsed, got, tk.want)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckMilestonesTarget(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	m, fake, rec := milestoneModel(t, now.Add(-time.Minute), UserConfig{})
+	task := m.tasks[m.ActiveTaskId]
+	// five minutes more than what's tracked today, whatever time of day the test runs
+	target := m.today.task(task.ID, now) + 5*time.Minute
+	task.DailyTarget = sql.NullInt64{Int64: int64(target / time.Second), Valid: true}
+	m.tasks[task.ID] = task
+	m = m.markTargetsMet(now)
+
+	for i, tk := range []struct {
+		at   time.Time
+		want int
+	}{
+		{at: now, want: 0},
+		{at: now.Add(4 * time.Minute), want: 0},
+		{at: now.Add(5 * time.Minute), want: 1},
+		{at: now.Add(6 * time.Minute), want: 1},
+	} {
+		var cmd tea.Cmd
+		m, cmd = m.checkMilestones(tk.at)
+		runCmd(cmd)
+		if got := len(fake.Sent()); got != tk.want {
+			t.Errorf("tick %d: %d notifications, want %d: %v", i, got, tk.want, titles(fake.Sent()))
+		}
+	}
+	if got := titles(fake.Sent()); len(got) != 1 || got[0] != "Daily target reached" {
+		t.Errorf("sent %v", got)
+	}
+	reached := 0
+	for _, name := range rec.names() {
+		if name == eventTargetReached {
+			reached++
+		}
+	}
+	if reached != 1 {
+		t.Errorf("%d target_reached events, want 1: %v", reached, rec.names())
+	}
+
+	// a target met before the session is resumed isn't announced again
+	task.DailyTarget.Int64 = 30
+	m.tasks[task.ID] = task
+	resumed := m.refreshToday()
+	resumed.milestones.sessionID = 0
+	_, cmd := resumed.checkMilestones(now)
+	runCmd(cmd)
+	if got := len(fake.Sent()); got != 1 {
+		t.Errorf("resuming announced again: %v", titles(fake.Sent()))
+	}
+}
@@ -0,0 +1,112 @@
+package notify
+
+import (
+	"errors"
+	"os/exec"
+	"sync"
+)
+
+// shows a desktop notification
+type Notifier interface {
+	Notify(title, body string) error
+}
+
+var ErrUnavailable = errors.New("notifier unavailable")
+
+// name the notifications are sent from
+const appName = "negentropy"
+
+// picks a notifier by its config name: "auto", "notify-send", "dbus" or "none".
+// Unknown names fall back to auto
+func FromName(name string) Notifier {
+	switch name {
+	case "none":
+		return None{}
+	case "notify-send":
+		return NotifySend{}
+	case "dbus":
+		return DBus{}
+	}
+	return First{NotifySend{}, DBus{}}
+}
+
+// drops everything, used when notifications are turned off
+type None struct{}
+
+func (None) Notify(title, body string) error {
+	return nil
+}
+
+// libnotify's command line tool
+type NotifySend struct{}
+
+func (NotifySend) Notify(title, body string) error {
+	if _, err := exec.LookPath("notify-send"); err != nil {
+		return ErrUnavailable
+	}
+	return exec.Command("notify-send", "--app-name="+appName, title, body).Run()
+}
+
+// calls org.freedesktop.Notifications on the session bus through gdbus, for desktops
+// without libnotify installed
+type DBus struct{}
+
+func (DBus) Notify(title, body string) error {
+	if _, err := exec.LookPath("gdbus"); err != nil {
+		return ErrUnavailable
+	}
+	return exec.Command("gdbus", "call", "--session",
+		"--dest", "org.freedesktop.Notifications",
+		"--object-path", "/org/freedesktop/Notifications",
+		"--method", "org.freedesktop.Notifications.Notify",
+		// app name, replaces id, icon, summary, body, actions, hints, timeout (-1 is the server's default)
+		appName, "0", "", title, body, "[]", "{}", "-1").Run()
+}
+
+// sends through the first notifier that's available
+type First []Notifier
+
+func (f First) Notify(title, body string) error {
+	for _, n := range f {
+		err := n.Notify(title, body)
+		if !errors.Is(err, ErrUnavailable) {
+			return err
+		}
+	}
+	return ErrUnavailable
+}
+
+type Notification struct {
+	Title string
+	Body  string
+}
+
+// keeps notifications in memory for tests and manual testing, safe to use from several goroutines
+type Fake struct {
+	mu   sync.Mutex
+	sent []Notification
+	err  error
+}
+
+func (f *Fake) Notify(title, body string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return f.err
+	}
+	f.sent = append(f.sent, Notification{Title: title, Body: body})
+	return nil
+}
+
+func (f *Fake) SetErr(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.err = err
+}
+
+// everything sent so far
+func (f *Fake) Sent() []Notification {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Notification(nil), f.sent...)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// where negentropy keeps its files. They're found the same way from every directory, the
+// working directory only picks the task (see projects.go). NEGENTROPY_CONFIG and NEGENTROPY_DB
+// point somewhere else, like a checkout with neg.config.json and database/appdb.sqlite
+
+// $NEGENTROPY_CONFIG, or neg.config.json in $XDG_CONFIG_HOME/negentropy (~/.config)
+func configPath() (string, error) {
+	return pathFromEnv("NEGENTROPY_CONFIG", "XDG_CONFIG_HOME", ".config", "neg.config.json")
+}
+
+// $NEGENTROPY_DB, or appdb.sqlite in $XDG_DATA_HOME/negentropy (~/.local/share)
+func databasePath() (string, error) {
+	return pathFromEnv("NEGENTROPY_DB", "XDG_DATA_HOME", filepath.Join(".local", "share"), "appdb.sqlite")
+}
+
+// debug.log in $XDG_STATE_HOME/negentropy (~/.local/state)
+func logPath() (string, error) {
+	return pathFromEnv("", "XDG_STATE_HOME", filepath.Join(".local", "state"), "debug.log")
+}
+
+// $XDG_STATE_HOME/negentropy/sync-device, or the same under ~/.local/state
+func syncDevicePath() (string, error) {
+	return pathFromEnv("", "XDG_STATE_HOME", filepath.Join(".local", "state"), "sync-device")
+}
+
+// the file named by the env var override, or else name in the negentropy directory of the
+// xdg base directory, which defaults to fallback under the home directory
+func pathFromEnv(override, xdg, fallback, name string) (string, error) {
+	if p := os.Getenv(override); override != "" && p != "" {
+		return filepath.Abs(p)
+	}
+	dir := os.Getenv(xdg)
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, fallback)
+	}
+	return filepath.Join(dir, "negentropy", name), nil
+}
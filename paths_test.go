@@ -0,0 +1,34 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestPaths(t *testing.T) {
+	t.Setenv("HOME", "/home/me")
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("XDG_DATA_HOME", "/data")
+	t.Setenv("XDG_STATE_HOME", "")
+	t.Setenv("NEGENTROPY_CONFIG", "")
+	t.Setenv("NEGENTROPY_DB", "/srv/negentropy/database/appdb.sqlite")
+
+	for name, tt := range map[string]struct {
+		path func() (string, error)
+		want string
+	}{
+		"config":      {configPath, "/home/me/.config/negentropy/neg.config.json"},
+		"database":    {databasePath, "/srv/negentropy/database/appdb.sqlite"},
+		"log":         {logPath, "/home/me/.local/state/negentropy/debug.log"},
+		"sync device": {syncDevicePath, "/home/me/.local/state/negentropy/sync-device"},
+	} {
+		if got, err := tt.path(); err != nil || got != filepath.FromSlash(tt.want) {
+			t.Errorf("%s = %q, %v, want %q", name, got, err, tt.want)
+		}
+	}
+
+	t.Setenv("NEGENTROPY_DB", "")
+	if got, _ := databasePath(); got != "/data/negentropy/appdb.sqlite" {
+		t.Errorf("database without the override = %q", got)
+	}
+}
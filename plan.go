@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	db "github.com/chee-zer/negentropy/database/sqlc"
+	"github.com/chee-zer/negentropy/ical"
+)
+
+// maps calendar events to tasks, Match is a case insensitive regexp on the event summary
+type planRule struct {
+	Match string `json:"match"`
+	Task  string `json:"task"`
+}
+
+// a planned block with parsed times
+type plannedBlock struct {
+	TaskID int64
+	Title  string
+	Start  time.Time
+	End    time.Time
+}
+
+// rules are tried in order, then an event whose summary is exactly a task name goes to that task
+func matchTask(summary string, rules []planRule, tasks []db.Task) (db.Task, bool, error) {
+	for _, r := range rules {
+		re, err := regexp.Compile("(?i)" + r.Match)
+		if err != nil {
+			return db.Task{}, false, fmt.Errorf("plan rule %q: %w", r.Match, err)
+		}
+		if !re.MatchString(summary) {
+			continue
+		}
+		t, err := findTask(tasks, r.Task)
+		if err != nil {
+			return db.Task{}, false, fmt.Errorf("plan rule %q: %w", r.Match, err)
+		}
+		return t, true, nil
+	}
+	t, err := findTask(tasks, strings.TrimSpace(summary))
+	return t, err == nil && t.ID != entropyTaskID, nil
+}
+
+// events without a UID get a stable one so re-imports still update instead of duplicate.
+// Occurrences of a recurring event share its UID, the recurrence id tells them apart
+func eventUID(ev ical.Event) string {
+	uid := ev.UID
+	if uid == "" {
+		sum := sha1.Sum([]byte(ev.Start.UTC().String() + ev.Summary))
+		uid = "negentropy-" + hex.EncodeToString(sum[:8])
+	}
+	if !ev.RecurrenceID.IsZero() {
+		uid += "/" + ev.RecurrenceID.UTC().Format("20060102T150405Z")
+	}
+	return uid
+}
+
+// stores every event that maps to a task as a planned block, unmatched summaries are returned
+func importPlan(ctx context.Context, q db.Querier, events []ical.Event, rules []planRule, dryRun bool, w io.Writer) (int, []string, error) {
+	tasks, err := q.GetTasks(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+	imported := 0
+	var unmatched []string
+	for _, ev := range events {
+		task, ok, err := matchTask(ev.Summary, rules, tasks)
+		if err != nil {
+			return imported, unmatched, err
+		}
+		if !ok {
+			unmatched = append(unmatched, ev.Summary)
+			continue
+		}
+		fmt.Fprintf(w, "  %s - %s  %-20s %s\n", ev.Start.In(dayLocation).Format("2006-01-02 15:04"), ev.End.In(dayLocation).Format("15:04"), task.Name, ev.Summary)
+		imported++
+		if dryRun {
+			continue
+		}
+		_, err = q.UpsertPlannedBlock(ctx, db.UpsertPlannedBlockParams{
+			TaskID:    task.ID,
+			StartTime: formatTimestamp(ev.Start),
+			EndTime:   formatTimestamp(ev.End),
+			Title:     ev.Summary,
+			SourceUid: eventUID(ev),
+		})
+		if err != nil {
+			return imported, unmatched, err
+		}
+	}
+	return imported, unmatched, nil
+}
+
+func loadPlannedBlocks(ctx context.Context, q db.Querier, from, to time.Time) ([]plannedBlock, error) {
+	rows, err := q.GetPlannedBlocksInRange(ctx, db.GetPlannedBlocksInRangeParams{
+		RangeStart: formatTimestamp(from),
+		RangeEnd:   formatTimestamp(to),
+	})
+	if err != nil {
+		return nil, err
+	}
+	blocks := make([]plannedBlock, 0, len(rows))
+	for _, r := range rows {
+		start, err := parseTimestamp(r.StartTime)
+		if err != nil {
+			return nil, fmt.Errorf("planned block %d: %w", r.ID, err)
+		}
+		end, err := parseTimestamp(r.EndTime)
+		if err != nil {
+			return nil, fmt.Errorf("planned block %d: %w", r.ID, err)
+		}
+		blocks = append(blocks, plannedBlock{TaskID: r.TaskID, Title: r.Title, Start: start, End: end})
+	}
+	return blocks, nil
+}
+
+// planned vs actual for one day: per task totals, then how much of each block was
+// actually spent on its task
+func renderPlanReport(w io.Writer, blocks []plannedBlock, spans []span, tasks map[int64]db.Task, day time.Time) {
+	dayEnd := day.AddDate(0, 0, 1)
+	fmt.Fprintf(w, "Planned vs actual, %s\n\n", day.Format("Monday, 2006-01-02"))
+
+	planned := make(map[int64]time.Duration)
+	var order []int64
+	for _, b := range blocks {
+		if _, ok := planned[b.TaskID]; !ok {
+			order = append(order, b.TaskID)
+		}
+		planned[b.TaskID] += minTime(b.End, dayEnd).Sub(maxTime(b.Start, day))
+	}
+	actual := dailyTotals(spans)[day]
+	var unplanned []int64
+	for id := range actual {
+		if _, ok := planned[id]; !ok {
+			unplanned = append(unplanned, id)
+		}
+	}
+	slices.Sort(unplanned)
+	order = append(order, unplanned...)
+	for _, id := range order {
+		p, a := planned[id], actual[id]
+		fmt.Fprintf(w, "  %-20s planned %-8s actual %-8s %s\n", taskName(tasks, id), formatDuration(p), formatDuration(a), diffString(a-p))
+	}
+
+	if len(blocks) == 0 {
+		fmt.Fprintln(w, "\nNothing planned, import a calendar with `negentropy plan import <file.ics>`")
+		return
+	}
+	fmt.Fprintln(w, "\nBlocks")
+	for _, b := range blocks {
+		var onTask time.Duration
+		for _, s := range spans {
+			if s.TaskID != b.TaskID || s.isBreak() {
+				continue
+			}
+			if part, ok := s.clip(b.Start, b.End); ok {
+				onTask += part.duration()
+			}
+		}
+		length := b.End.Sub(b.Start)
+		fmt.Fprintf(w, "  %s - %s  %-20s %s %3.0f%%\n", b.Start.Format("15:04"), b.End.Format("15:04"),
+			taskName(tasks, b.TaskID), bar(onTask.Seconds()/length.Seconds(), 20), 100*onTask.Seconds()/length.Seconds())
+	}
+}
+
+func diffString(d time.Duration) string {
+	if d < 0 {
+		return "-" + formatDuration(-d)
+	}
+	return "+" + formatDuration(d)
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// blocks of today that haven't ended yet, for the main view
+func (m model) planView() string {
+	now := time.Now()
+	var parts []string
+	for _, b := range m.plannedToday {
+		if !b.End.After(now) {
+			continue
+		}
+		when := "in " + formatDuration(b.Start.Sub(now))
+		if !b.Start.After(now) {
+			when = "now"
+		}
+		parts = append(parts, fmt.Sprintf("%s-%s %s (%s)", b.Start.Format("15:04"), b.End.Format("15:04"), taskName(m.tasks, b.TaskID), when))
+		if len(parts) == 3 {
+			break
+		}
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "planned: " + strings.Join(parts, ", ")
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/chee-zer/negentropy/ical"
+)
+
+func TestEventUID(t *testing.T) {
+	start := time.Date(2026, 3, 2, 7, 0, 0, 0, time.UTC)
+	first := ical.Event{UID: "gym", Start: start, RecurrenceID: start}
+	moved := ical.Event{UID: "gym", Start: start.Add(time.Hour), RecurrenceID: start}
+	next := ical.Event{UID: "gym", Start: start.AddDate(0, 0, 1), RecurrenceID: start.AddDate(0, 0, 1)}
+
+	if got := eventUID(ical.Event{UID: "once", Start: start}); got != "once" {
+		t.Errorf("uid of an event that doesn't recur = %q", got)
+	}
+	if eventUID(first) == eventUID(next) {
+		t.Error("two occurrences share a uid")
+	}
+	if eventUID(first) != eventUID(moved) {
+		t.Error("a moved occurrence got a new uid")
+	}
+	if eventUID(ical.Event{Start: start, Summary: "x"}) == eventUID(ical.Event{Start: start, Summary: "y"}) {
+		t.Error("events without a uid share one")
+	}
+}
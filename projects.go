@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path"
+	"path/filepath"
+	"strings"
+
+	db "github.com/chee-zer/negentropy/database/sqlc"
+	"github.com/chee-zer/negentropy/gitlog"
+)
+
+// picks the task for a working directory, see project_tasks in the config
+type projectTask struct {
+	Dir    string `json:"dir"`    // this directory and everything under it
+	Remote string `json:"remote"` // a remote of the repo, like github.com/chee-zer/*
+	Task   string `json:"task"`
+}
+
+func validateProjectTasks(projects []projectTask) error {
+	for _, p := range projects {
+		if p.Task == "" {
+			return fmt.Errorf("project_tasks entry without a task")
+		}
+		if (p.Dir == "") == (p.Remote == "") {
+			return fmt.Errorf("project_tasks entry for %q needs either a dir or a remote", p.Task)
+		}
+		if _, err := path.Match(gitlog.NormalizeRemote(p.Remote), ""); err != nil {
+			return fmt.Errorf("project_tasks remote %q: %w", p.Remote, err)
+		}
+	}
+	return nil
+}
+
+// the task for working in dir: the deepest dir mapping containing it, git_repos with a task
+// count as one, or else the first remote mapping that matches a remote of dir's repo.
+// Mappings to tasks that don't exist are skipped. why says which mapping it was
+func taskForDir(ctx context.Context, dir string, projects []projectTask, repos []gitRepo, tasks []db.Task) (task db.Task, why string, ok bool) {
+	dirs := make([]projectTask, 0, len(projects)+len(repos))
+	for _, p := range projects {
+		if p.Dir != "" {
+			dirs = append(dirs, p)
+		}
+	}
+	for _, r := range repos {
+		if r.Task != "" {
+			dirs = append(dirs, projectTask{Dir: r.Path, Task: r.Task})
+		}
+	}
+	dir = canonicalPath(dir)
+	deepest := ""
+	for _, p := range dirs {
+		root := canonicalPath(p.Dir)
+		if !within(dir, root) || len(root) <= len(deepest) {
+			continue
+		}
+		t, err := findTask(tasks, p.Task)
+		if err != nil {
+			log.Printf("project_tasks %s: %v", p.Dir, err)
+			continue
+		}
+		task, why, ok, deepest = t, "in "+p.Dir, true, root
+	}
+	if ok {
+		return task, why, true
+	}
+
+	remotes, err := gitlog.Remotes(ctx, dir)
+	if err != nil || len(remotes) == 0 {
+		return db.Task{}, "", false
+	}
+	for _, p := range projects {
+		if p.Remote == "" {
+			continue
+		}
+		pattern := gitlog.NormalizeRemote(p.Remote)
+		for _, remote := range remotes {
+			if matched, _ := path.Match(pattern, remote); !matched {
+				continue
+			}
+			t, err := findTask(tasks, p.Task)
+			if err != nil {
+				log.Printf("project_tasks %s: %v", p.Remote, err)
+				break
+			}
+			return t, "remote " + remote, true
+		}
+	}
+	return db.Task{}, "", false
+}
+
+// absolute and without symlinks when possible, so two spellings of a directory compare equal
+func canonicalPath(p string) string {
+	if abs, err := filepath.Abs(p); err == nil {
+		p = abs
+	}
+	if real, err := filepath.EvalSymlinks(p); err == nil {
+		p = real
+	}
+	return p
+}
+
+// whether dir is root or somewhere under it
+func within(dir, root string) bool {
+	rel, err := filepath.Rel(root, dir)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
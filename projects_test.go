@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	db "github.com/chee-zer/negentropy/database/sqlc"
+)
+
+// a git repository at dir with the remotes origin, second, ...
+func gitInit(t *testing.T, dir string, remotes ...string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("no git")
+	}
+	t.Setenv("GIT_CONFIG_GLOBAL", "/dev/null")
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	cmds := [][]string{{"init", "-q"}}
+	for i, url := range remotes {
+		cmds = append(cmds, []string{"remote", "add", []string{"origin", "second"}[i], url})
+	}
+	for _, args := range cmds {
+		if out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+}
+
+func TestWithin(t *testing.T) {
+	tests := []struct {
+		dir, root string
+		want      bool
+	}{
+		{dir: "/home/me/work", root: "/home/me/work", want: true},
+		{dir: "/home/me/work/app/src", root: "/home/me/work", want: true},
+		{dir: "/home/me/workshop", root: "/home/me/work", want: false},
+		{dir: "/home/me", root: "/home/me/work", want: false},
+		{dir: "/home/me/..work", root: "/home/me", want: true},
+		{dir: "/srv", root: "/", want: true},
+	}
+	for _, tt := range tests {
+		if got := within(tt.dir, tt.root); got != tt.want {
+			t.Errorf("within(%q, %q) = %v, want %v", tt.dir, tt.root, got, tt.want)
+		}
+	}
+}
+
+func TestTaskForDir(t *testing.T) {
+	root := canonicalPath(t.TempDir())
+	for _, d := range []string{"work/src", "work/docs/api", "workshop", "oss/app", "oss/site", "plain"} {
+		if err := os.MkdirAll(filepath.Join(root, d), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(root, "work", "docs"), filepath.Join(root, "docs-link")); err != nil {
+		t.Fatal(err)
+	}
+	gitInit(t, filepath.Join(root, "oss", "app"), "git@github.com:Chee-Zer/app.git")
+	gitInit(t, filepath.Join(root, "oss", "site"), "https://gitlab.com/me/fork.git", "https://github.com/me/site")
+
+	tasks := []db.Task{{ID: 0, Name: "ENTROPY"}, {ID: 1, Name: "code"}, {ID: 2, Name: "docs"}, {ID: 3, Name: "oss"}, {ID: 4, Name: "site"}}
+	projects := []projectTask{
+		// the deeper mapping wins whatever the order
+		{Dir: filepath.Join(root, "work", "docs"), Task: "docs"},
+		{Dir: filepath.Join(root, "work"), Task: "code"},
+		{Dir: filepath.Join(root, "workshop"), Task: "gone"},
+		{Remote: "github.com/chee-zer/*", Task: "oss"},
+		{Remote: "https://github.com/me/site.git", Task: "site"},
+	}
+	repos := []gitRepo{{Path: filepath.Join(root, "docs-link", "api"), Task: "3"}}
+
+	tests := []struct {
+		name string
+		dir  string
+		want string // task name, empty for none
+		why  string
+	}{
+		{name: "mapped dir", dir: "work", want: "code", why: "in " + filepath.Join(root, "work")},
+		{name: "below a mapped dir", dir: "work/src", want: "code"},
+		{name: "deepest wins", dir: "work/docs", want: "docs"},
+		{name: "git repo task", dir: "work/docs/api", want: "oss", why: "in " + filepath.Join(root, "docs-link", "api")},
+		{name: "through a symlink", dir: "docs-link", want: "docs"},
+		{name: "mapped to a missing task", dir: "workshop"},
+		{name: "scp remote matching a glob", dir: "oss/app", want: "oss", why: "remote github.com/chee-zer/app"},
+		{name: "https remote, not the first", dir: "oss/site", want: "site", why: "remote github.com/me/site"},
+		{name: "nothing", dir: "plain"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task, why, ok := taskForDir(context.Background(), filepath.Join(root, tt.dir), projects, repos, tasks)
+			if ok != (tt.want != "") || task.Name != tt.want {
+				t.Fatalf("got %q (%v), want %q", task.Name, ok, tt.want)
+			}
+			if tt.why != "" && why != tt.why {
+				t.Errorf("why = %q, want %q", why, tt.why)
+			}
+		})
+	}
+}
+
+func TestNewModelFirstTask(t *testing.T) {
+	_, q := openTestDB(t)
+	ctx := context.Background()
+	for _, name := range []string{"first", "second"} {
+		if _, err := q.CreateTask(ctx, db.CreateTaskParams{Name: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := q.DeleteTask(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	m := NewModel(q, UserConfig{Notifier: "none"}, nil, localTimer{q: q}, false, &dispatcher{})
+	if m.ActiveTaskId != 2 || m.tabs.Tasks[m.tabs.ActiveTabIndex].ID != 2 {
+		t.Errorf("active task %d, tab %d, want the first task that's left", m.ActiveTaskId, m.tabs.ActiveTabIndex)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/chee-zer/negentropy/aggregate"
+	db "github.com/chee-zer/negentropy/database/sqlc"
+)
+
+// id of the ENTROPY task seeded by the migrations, reset sessions are moved here
+const entropyTaskID int64 = 0
+
+// values of sessions.kind
+const (
+	sessionWork  = "work"
+	sessionBreak = "break"
+)
+
+// a session with parsed times, running sessions end at the time they were loaded
+type span struct {
+	SessionID    int64
+	TaskID       int64
+	OriginTaskID int64 // task an entropy session was taken from, 0 if unknown
+	Reason       string
+	Kind         string
+	Start        time.Time
+	End          time.Time
+	Running      bool
+}
+
+func (s span) isEntropy() bool {
+	return s.TaskID == entropyTaskID
+}
+
+// breaks keep their task but aren't tracked time
+func (s span) isBreak() bool {
+	return s.Kind == sessionBreak
+}
+
+func (s span) duration() time.Duration {
+	return s.End.Sub(s.Start)
+}
+
+// returns the part of the span inside [from, to), ok is false if they don't overlap
+func (s span) clip(from, to time.Time) (span, bool) {
+	if s.Start.Before(from) {
+		s.Start = from
+	}
+	if s.End.After(to) {
+		s.End = to
+	}
+	return s, s.End.After(s.Start)
+}
+
+func spanFromSession(s db.Session, now time.Time) (span, error) {
+	start, err := parseTimestamp(s.StartTime)
+	if err != nil {
+		return span{}, fmt.Errorf("session %d: %w", s.ID, err)
+	}
+	sp := span{
+		SessionID:    s.ID,
+		TaskID:       s.TaskID,
+		OriginTaskID: s.OriginTaskID.Int64,
+		Reason:       s.EntropyReason.String,
+		Kind:         s.Kind,
+		Start:        start,
+		End:          now,
+		Running:      !s.EndTime.Valid,
+	}
+	if s.EndTime.Valid {
+		if sp.End, err = parseTimestamp(s.EndTime.String); err != nil {
+			return span{}, fmt.Errorf("session %d: %w", s.ID, err)
+		}
+	}
+	return sp, nil
+}
+
+// loads every session overlapping [from, to), clipped to the range
+func loadSpans(ctx context.Context, q db.Querier, from, to time.Time) ([]span, error) {
+	sessions, err := q.GetSessionsInRange(ctx, db.GetSessionsInRangeParams{
+		RangeStart: formatTimestamp(from),
+		RangeEnd:   formatTimestamp(to),
+	})
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	spans := make([]span, 0, len(sessions))
+	for _, s := range sessions {
+		sp, err := spanFromSession(s, now)
+		if err != nil {
+			return nil, err
+		}
+		if sp, ok := sp.clip(from, to); ok {
+			spans = append(spans, sp)
+		}
+	}
+	return spans, nil
+}
+
+func (s span) interval() aggregate.Interval {
+	return aggregate.Interval{Key: s.TaskID, Start: s.Start, End: s.End}
+}
+
+// tracked time per bucket and task, spans crossing a bucket boundary are split between the
+// buckets. Entropy and breaks are left out
+func trackedTotals(spans []span, unit aggregate.Unit) aggregate.Totals {
+	ivs := make([]aggregate.Interval, 0, len(spans))
+	for _, s := range spans {
+		if !s.isEntropy() && !s.isBreak() {
+			ivs = append(ivs, s.interval())
+		}
+	}
+	// loaded spans already end at the time they were loaded, so now is never used
+	return calendar().Sum(ivs, unit, time.Now())
+}
+
+func dailyTotals(spans []span) aggregate.Totals {
+	return trackedTotals(spans, aggregate.Day)
+}
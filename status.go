@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	db "github.com/chee-zer/negentropy/database/sqlc"
+)
+
+// placeholders for `negentropy status --format`, all empty or "-" when they don't apply
+var statusFields = []string{"task", "elapsed", "start", "today", "task_today", "target", "percent"}
+
+// the values for a status line. Only reads the database (the running session and today's
+// sessions), so it's cheap enough for a status bar polling every second
+func loadStatusValues(ctx context.Context, q db.Querier, now time.Time) (map[string]string, bool, error) {
+	today, err := loadTodayStats(ctx, q, now)
+	if err != nil {
+		return nil, false, err
+	}
+	values := map[string]string{
+		"task":       "",
+		"elapsed":    "",
+		"start":      "",
+		"today":      formatDuration(today.total(now)),
+		"task_today": "-",
+		"target":     "-",
+		"percent":    "-",
+	}
+
+	running, err := localTimer{q: q}.Status(ctx)
+	if err != nil || !running.Running() {
+		return values, false, err
+	}
+	session := running.Session
+	start, err := parseTimestamp(session.StartTime)
+	if err != nil {
+		return nil, false, err
+	}
+	tasks, err := q.GetTasks(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	values["task"] = fmt.Sprintf("#%d", session.TaskID)
+	values["elapsed"] = formatDuration(now.Sub(start))
+	values["start"] = start.Format("15:04")
+	// a session that started before the day boundary only counts from there
+	values["task_today"] = formatDuration(today.task(session.TaskID, now))
+	for _, t := range tasks {
+		if t.ID != session.TaskID {
+			continue
+		}
+		values["task"] = t.Name
+		if t.DailyTarget.Valid && t.DailyTarget.Int64 > 0 {
+			target := time.Duration(t.DailyTarget.Int64) * time.Second
+			values["target"] = formatDuration(target)
+			values["percent"] = fmt.Sprintf("%.0f%%", 100*today.task(t.ID, now).Seconds()/target.Seconds())
+		}
+	}
+	return values, true, nil
+}
+
+// fills in {name} placeholders, {{ and }} are literal braces
+func renderStatus(format string, values map[string]string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		switch {
+		case (c == '{' || c == '}') && i+1 < len(format) && format[i+1] == c:
+			b.WriteByte(c)
+			i++
+		case c == '{':
+			end := strings.IndexByte(format[i:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("unclosed { in format %q", format)
+			}
+			name := format[i+1 : i+end]
+			v, ok := values[name]
+			if !ok {
+				return "", fmt.Errorf("unknown placeholder {%s}, use one of {%s}", name, strings.Join(statusFields, "} {"))
+			}
+			b.WriteString(v)
+			i += end
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	db "github.com/chee-zer/negentropy/database/sqlc"
+)
+
+func TestStatusMain(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "appdb.sqlite")
+	sqldb := openTestDBAt(t, path, -1)
+	q := db.New(sqldb)
+	ctx := context.Background()
+	task, err := q.CreateTask(ctx, db.CreateTaskParams{Name: "code"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (localTimer{q: q}).Start(ctx, task.ID, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("NEGENTROPY_DB", path)
+	t.Setenv("NEGENTROPY_CONFIG", filepath.Join(dir, "missing.json"))
+	// somewhere else than the database, like a status bar
+	t.Chdir(t.TempDir())
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	err = statusMain([]string{"--format", "{task}"})
+	os.Stdout = stdout
+	w.Close()
+	out, _ := io.ReadAll(r)
+	if err != nil || string(out) != "code\n" {
+		t.Errorf("status = %q, %v", out, err)
+	}
+
+	ro, err := sql.Open("sqlite3", (&url.URL{Scheme: "file", Path: path, RawQuery: "mode=ro"}).String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ro.Close()
+	if _, err := ro.Exec("DELETE FROM sessions"); err == nil {
+		t.Error("the status connection can write")
+	}
+}
@@ -0,0 +1,775 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	db "github.com/chee-zer/negentropy/database/sqlc"
+)
+
+// sync between devices through a shared folder (syncthing, dropbox, a network mount). Every
+// device appends the changes it made to its own <device>.ndjson log in there and merges
+// everyone else's. Changes are found by comparing the db with sync_entities, the state after
+// the last sync, so nothing outside this file needs to know about sync
+
+const syncLogExt = ".ndjson"
+
+const (
+	syncTask    = "task"
+	syncSession = "session"
+
+	syncCreate = "create"
+	syncUpdate = "update"
+	syncDelete = "delete"
+
+	// task 0 exists on every device and is never synced itself
+	entropyTaskUID = "entropy"
+)
+
+// one line of a device's log
+type syncOp struct {
+	Device string          `json:"device"`
+	Clock  int64           `json:"clock"` // lamport timestamp
+	Op     string          `json:"op"`
+	Kind   string          `json:"kind"`
+	UID    string          `json:"uid"`
+	Data   json.RawMessage `json:"data,omitempty"`
+	// where the line starts in the log, to read it again when it couldn't be applied
+	offset int64
+}
+
+// last writer wins, by lamport clock and then device id so every device picks the same one
+func (op syncOp) newerThan(e db.SyncEntity) bool {
+	if op.Clock != e.Clock {
+		return op.Clock > e.Clock
+	}
+	return op.Device > e.Device
+}
+
+type syncedTask struct {
+	Name        string `json:"name"`
+	Color       string `json:"color,omitempty"`
+	Completed   bool   `json:"completed,omitempty"`
+	DailyTarget int64  `json:"daily_target,omitempty"`
+}
+
+// times in the UTC layout, tasks by uid
+type syncedSession struct {
+	Start         string `json:"start"`
+	End           string `json:"end"`
+	Task          string `json:"task"`
+	OriginTask    string `json:"origin_task,omitempty"`
+	EntropyReason string `json:"entropy_reason,omitempty"`
+	Kind          string `json:"kind"`
+}
+
+type syncStats struct {
+	Device   string
+	Sent     int
+	Received int
+	Applied  int
+	Skipped  int
+	Trimmed  int
+	Removed  int
+	// tasks that didn't exist here before
+	CreatedTasks []int64
+}
+
+type syncer struct {
+	q        db.Querier
+	self     db.SyncDevice
+	peers    map[string]db.SyncDevice
+	entities map[string]db.SyncEntity
+	// kind -> local id -> uid. A task can have more than one uid when two devices created
+	// it under the same name, the smallest is used so every device refers to it the same way
+	local map[string]map[int64]string
+	ops   []syncOp
+	stats syncStats
+}
+
+// the own log of a device has changes its database doesn't know about
+var errSyncLogAhead = errors.New("the sync log of this device has changes the database doesn't know about, the database was probably restored from a backup or copied along with the device id")
+
+// sends the local changes of device to dir and merges the other devices' changes into the db.
+// Meant to run in a transaction. The changes go to sync_outbox and only reach the log with
+// flushSyncLog after the commit, a crash in between leaves them there for the next sync
+func syncFolder(ctx context.Context, q db.Querier, dir, device string) (syncStats, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return syncStats{Device: device}, err
+	}
+	// whatever the last sync committed but didn't get to write
+	if err := flushSyncLog(ctx, q, dir); err != nil {
+		return syncStats{Device: device}, err
+	}
+	s, err := loadSyncer(ctx, q, device)
+	if err != nil {
+		return syncStats{Device: device}, err
+	}
+	// the db is at the clock of its last sync, a log going further was written by another db
+	logged, err := loggedClock(filepath.Join(dir, device+syncLogExt), device)
+	if err != nil {
+		return s.stats, err
+	}
+	if logged > s.self.Clock {
+		return s.stats, fmt.Errorf("%w (log at clock %d, database at %d)", errSyncLogAhead, logged, s.self.Clock)
+	}
+	// a db copied from another device comes with that device's clock, changes made here have
+	// to be newer or they'd lose against what they change
+	for _, p := range s.peers {
+		s.self.Clock = max(s.self.Clock, p.Clock)
+	}
+
+	if err := s.export(ctx); err != nil {
+		return s.stats, err
+	}
+	ops, err := s.readLogs(dir)
+	if err != nil {
+		return s.stats, err
+	}
+	for _, op := range ops {
+		if err := s.apply(ctx, op); err != nil {
+			log.Printf("sync: skipped %s %s %s from %s, retrying next sync: %v", op.Op, op.Kind, op.UID, op.Device, err)
+			s.stats.Skipped++
+			s.readAgain(op)
+		}
+	}
+	if err := s.resolveOverlaps(ctx); err != nil {
+		return s.stats, err
+	}
+	// the overlap fixes are changes of this device like any other
+	if err := s.export(ctx); err != nil {
+		return s.stats, err
+	}
+	if err := s.queue(ctx); err != nil {
+		return s.stats, err
+	}
+	return s.stats, s.saveDevices(ctx)
+}
+
+// the row of device is this device, every other one is a peer. A db copied from another
+// device reads that device's log like any other then, and skips what it already has
+func loadSyncer(ctx context.Context, q db.Querier, device string) (*syncer, error) {
+	s := &syncer{
+		q:        q,
+		self:     db.SyncDevice{Device: device},
+		peers:    make(map[string]db.SyncDevice),
+		entities: make(map[string]db.SyncEntity),
+		local:    map[string]map[int64]string{syncTask: {}, syncSession: {}},
+		stats:    syncStats{Device: device},
+	}
+	devices, err := q.GetSyncDevices(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range devices {
+		if d.Device == device {
+			s.self = d
+		} else {
+			s.peers[d.Device] = d
+		}
+	}
+	entities, err := q.GetSyncEntities(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entities {
+		s.entities[e.Uid] = e
+		s.link(e)
+	}
+	return s, nil
+}
+
+// the id of this device in the sync folder, made up on the first sync. It's kept outside the
+// db so a copy of the db, or a backup restored on another machine, syncs as a device of its own
+func syncDeviceID(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	if id := strings.TrimSpace(string(data)); id != "" {
+		// it names the log and starts every uid
+		if strings.ContainsAny(id, "-/\\ ") {
+			return "", fmt.Errorf("bad device id %q in %s", id, path)
+		}
+		return id, nil
+	}
+	id := randomID(8)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+	return id, os.WriteFile(path, []byte(id+"\n"), 0o644)
+}
+
+// the device a task or session was created on, the first part of its uid
+func syncOrigin(uid string) string {
+	device, _, _ := strings.Cut(uid, "-")
+	return device
+}
+
+func randomID(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// appends an op for every task and finished session that changed since the last sync
+func (s *syncer) export(ctx context.Context) error {
+	seen := map[string]map[int64]bool{syncTask: {}, syncSession: {}}
+	tasks, err := s.q.GetTasks(ctx)
+	if err != nil {
+		return err
+	}
+	for _, t := range tasks {
+		if t.ID == 0 {
+			continue
+		}
+		seen[syncTask][t.ID] = true
+		data := syncedTask{Name: t.Name, Color: t.ColorHex.String, Completed: t.Completed.Bool, DailyTarget: t.DailyTarget.Int64}
+		if err := s.record(ctx, syncTask, t.ID, data); err != nil {
+			return err
+		}
+	}
+	sessions, err := s.allSessions(ctx)
+	if err != nil {
+		return err
+	}
+	for _, ss := range sessions {
+		// running sessions are synced once they end
+		if !ss.EndTime.Valid {
+			continue
+		}
+		data, ok := s.syncedSession(ss)
+		if !ok {
+			continue
+		}
+		seen[syncSession][ss.ID] = true
+		if err := s.record(ctx, syncSession, ss.ID, data); err != nil {
+			return err
+		}
+	}
+	for _, uid := range slices.Sorted(maps.Keys(s.entities)) {
+		e := s.entities[uid]
+		if e.Deleted || seen[e.Kind][e.LocalID] {
+			continue
+		}
+		if err := s.emit(ctx, syncOp{Op: syncDelete, Kind: e.Kind, UID: uid}, e.LocalID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *syncer) allSessions(ctx context.Context) ([]db.Session, error) {
+	return s.q.GetSessionsInRange(ctx, db.GetSessionsInRangeParams{RangeStart: "", RangeEnd: "9999"})
+}
+
+// ok is false for sessions that can't be synced, like ones whose task was deleted
+func (s *syncer) syncedSession(ss db.Session) (syncedSession, bool) {
+	task, ok := s.taskUID(ss.TaskID)
+	if !ok {
+		return syncedSession{}, false
+	}
+	start, err := parseTimestamp(ss.StartTime)
+	if err != nil {
+		return syncedSession{}, false
+	}
+	end, err := parseTimestamp(ss.EndTime.String)
+	if err != nil {
+		return syncedSession{}, false
+	}
+	data := syncedSession{
+		Start:         formatTimestamp(start),
+		End:           formatTimestamp(end),
+		Task:          task,
+		EntropyReason: ss.EntropyReason.String,
+		Kind:          ss.Kind,
+	}
+	if ss.OriginTaskID.Valid {
+		data.OriginTask, _ = s.taskUID(ss.OriginTaskID.Int64)
+	}
+	return data, true
+}
+
+func (s *syncer) taskUID(id int64) (string, bool) {
+	if id == 0 {
+		return entropyTaskUID, true
+	}
+	uid, ok := s.local[syncTask][id]
+	return uid, ok
+}
+
+func (s *syncer) localTask(uid string) (int64, bool) {
+	if uid == entropyTaskUID {
+		return 0, true
+	}
+	e, ok := s.entities[uid]
+	if !ok || e.Kind != syncTask || e.Deleted {
+		return 0, false
+	}
+	return e.LocalID, true
+}
+
+// emits a create or update when the row differs from what was synced last
+func (s *syncer) record(ctx context.Context, kind string, localID int64, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	op := syncOp{Op: syncUpdate, Kind: kind, Data: data}
+	uid, ok := s.local[kind][localID]
+	if !ok {
+		uid = s.self.Device + "-" + randomID(8)
+		op.Op = syncCreate
+	} else if s.entities[uid].Data == string(data) {
+		return nil
+	}
+	op.UID = uid
+	return s.emit(ctx, op, localID)
+}
+
+// stamps a change of this device and queues it for the log
+func (s *syncer) emit(ctx context.Context, op syncOp, localID int64) error {
+	s.self.Clock++
+	op.Device, op.Clock = s.self.Device, s.self.Clock
+	s.ops = append(s.ops, op)
+	s.stats.Sent++
+	return s.remember(ctx, db.SyncEntity{
+		Uid:     op.UID,
+		Kind:    op.Kind,
+		LocalID: localID,
+		Data:    string(op.Data),
+		Clock:   op.Clock,
+		Device:  op.Device,
+		Deleted: op.Op == syncDelete,
+	})
+}
+
+func (s *syncer) remember(ctx context.Context, e db.SyncEntity) error {
+	old, ok := s.entities[e.Uid]
+	s.entities[e.Uid] = e
+	if ok && s.local[old.Kind][old.LocalID] == old.Uid && (e.Deleted || e.LocalID != old.LocalID) {
+		// another uid of the same row takes over, if there is one
+		delete(s.local[old.Kind], old.LocalID)
+		for _, other := range s.entities {
+			if other.Kind == old.Kind && other.LocalID == old.LocalID {
+				s.link(other)
+			}
+		}
+	}
+	s.link(e)
+	return s.q.UpsertSyncEntity(ctx, db.UpsertSyncEntityParams(e))
+}
+
+func (s *syncer) link(e db.SyncEntity) {
+	if e.Deleted {
+		return
+	}
+	if cur, ok := s.local[e.Kind][e.LocalID]; !ok || e.Uid < cur {
+		s.local[e.Kind][e.LocalID] = e.Uid
+	}
+}
+
+// new ops from the other devices' logs, in lamport order so tasks arrive before their sessions
+func (s *syncer) readLogs(dir string) ([]syncOp, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var ops []syncOp
+	for _, f := range files {
+		device, ok := strings.CutSuffix(f.Name(), syncLogExt)
+		if !ok || f.IsDir() || device == s.self.Device {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		peer, ok := s.peers[device]
+		if !ok {
+			peer = db.SyncDevice{Device: device}
+		}
+		if peer.ReadOffset > int64(len(data)) {
+			// replaced rather than appended to, reading it again is harmless
+			peer.ReadOffset = 0
+		}
+		chunk := completeLines(data[peer.ReadOffset:])
+		for _, op := range parseLog(f.Name(), chunk, device) {
+			op.offset += peer.ReadOffset
+			ops = append(ops, op)
+		}
+		peer.ReadOffset += int64(len(chunk))
+		peer.Clock = max(peer.Clock, lastClock(ops, device))
+		s.peers[device] = peer
+	}
+	slices.SortStableFunc(ops, func(a, b syncOp) int {
+		return cmp.Or(cmp.Compare(a.Clock, b.Clock), strings.Compare(a.Device, b.Device))
+	})
+	s.stats.Received = len(ops)
+	return ops, nil
+}
+
+// the next sync reads the log of op's device from op on again, say a session whose task
+// is in the log of a device that hasn't reached the folder yet. Ops after it that were
+// applied now are known by then and skipped
+func (s *syncer) readAgain(op syncOp) {
+	peer := s.peers[op.Device]
+	peer.ReadOffset = min(peer.ReadOffset, op.offset)
+	s.peers[op.Device] = peer
+}
+
+// a line without its newline is still being written or synced
+func completeLines(data []byte) []byte {
+	return data[:bytes.LastIndexByte(data, '\n')+1]
+}
+
+func parseLog(name string, data []byte, device string) []syncOp {
+	var ops []syncOp
+	var offset int64
+	for line := range bytes.Lines(data) {
+		var op syncOp
+		err := json.Unmarshal(line, &op)
+		op.offset = offset
+		offset += int64(len(line))
+		if err != nil || op.Device != device {
+			log.Printf("sync: bad line in %s: %s", name, bytes.TrimSpace(line))
+			continue
+		}
+		ops = append(ops, op)
+	}
+	return ops
+}
+
+func lastClock(ops []syncOp, device string) int64 {
+	var clock int64
+	for _, op := range ops {
+		if op.Device == device {
+			clock = max(clock, op.Clock)
+		}
+	}
+	return clock
+}
+
+// the last clock of device in the log at path, 0 when there's no log yet
+func loggedClock(path, device string) (int64, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return lastClock(parseLog(filepath.Base(path), completeLines(data), device), device), nil
+}
+
+func (s *syncer) apply(ctx context.Context, op syncOp) error {
+	s.self.Clock = max(s.self.Clock, op.Clock)
+	cur, known := s.entities[op.UID]
+	if known && !op.newerThan(cur) {
+		return nil
+	}
+	var localID int64
+	var err error
+	switch op.Kind {
+	case syncTask:
+		localID, err = s.applyTask(ctx, op)
+	case syncSession:
+		localID, err = s.applySession(ctx, op)
+	default:
+		err = fmt.Errorf("unknown kind %q", op.Kind)
+	}
+	if err != nil {
+		return err
+	}
+	s.stats.Applied++
+	return s.remember(ctx, db.SyncEntity{
+		Uid:     op.UID,
+		Kind:    op.Kind,
+		LocalID: localID,
+		Data:    string(op.Data),
+		Clock:   op.Clock,
+		Device:  op.Device,
+		Deleted: op.Op == syncDelete,
+	})
+}
+
+func (s *syncer) applyTask(ctx context.Context, op syncOp) (int64, error) {
+	cur, known := s.entities[op.UID]
+	live := known && !cur.Deleted
+	if op.Op == syncDelete {
+		if !live {
+			return cur.LocalID, nil
+		}
+		return cur.LocalID, s.q.DeleteTask(ctx, cur.LocalID)
+	}
+	var t syncedTask
+	if err := json.Unmarshal(op.Data, &t); err != nil {
+		return 0, err
+	}
+	id := cur.LocalID
+	if !live {
+		// created on both devices under the same name, they become one task
+		tasks, err := s.q.GetTasks(ctx)
+		if err != nil {
+			return 0, err
+		}
+		id = 0
+		for _, existing := range tasks {
+			if existing.ID != 0 && existing.Name == t.Name {
+				id = existing.ID
+			}
+		}
+		if id == 0 {
+			created, err := s.q.CreateTask(ctx, db.CreateTaskParams{Name: t.Name})
+			if err != nil {
+				return 0, err
+			}
+			id = created.ID
+			s.stats.CreatedTasks = append(s.stats.CreatedTasks, id)
+		}
+	}
+	return id, s.q.UpdateTask(ctx, db.UpdateTaskParams{
+		Name:        t.Name,
+		ColorHex:    sql.NullString{String: t.Color, Valid: t.Color != ""},
+		Completed:   sql.NullBool{Bool: t.Completed, Valid: true},
+		DailyTarget: sql.NullInt64{Int64: t.DailyTarget, Valid: t.DailyTarget != 0},
+		ID:          id,
+	})
+}
+
+func (s *syncer) applySession(ctx context.Context, op syncOp) (int64, error) {
+	cur, known := s.entities[op.UID]
+	live := known && !cur.Deleted
+	if op.Op == syncDelete {
+		if !live {
+			return cur.LocalID, nil
+		}
+		return cur.LocalID, s.q.DeleteSession(ctx, cur.LocalID)
+	}
+	var ss syncedSession
+	if err := json.Unmarshal(op.Data, &ss); err != nil {
+		return 0, err
+	}
+	taskID, ok := s.localTask(ss.Task)
+	if !ok {
+		return 0, fmt.Errorf("unknown task %s", ss.Task)
+	}
+	var origin sql.NullInt64
+	if ss.OriginTask != "" {
+		origin.Int64, origin.Valid = s.localTask(ss.OriginTask)
+	}
+	params := db.UpdateSessionParams{
+		StartTime:     ss.Start,
+		EndTime:       sql.NullString{String: ss.End, Valid: true},
+		TaskID:        taskID,
+		OriginTaskID:  origin,
+		EntropyReason: sql.NullString{String: ss.EntropyReason, Valid: ss.EntropyReason != ""},
+		Kind:          ss.Kind,
+		ID:            cur.LocalID,
+	}
+	if live {
+		return cur.LocalID, s.q.UpdateSession(ctx, params)
+	}
+	created, err := s.q.InsertSession(ctx, db.InsertSessionParams{
+		StartTime:     params.StartTime,
+		EndTime:       params.EndTime,
+		TaskID:        params.TaskID,
+		OriginTaskID:  params.OriginTaskID,
+		EntropyReason: params.EntropyReason,
+		Kind:          params.Kind,
+	})
+	return created.ID, err
+}
+
+// two devices tracking at the same time, usually because one was left running. The same
+// session from both, like after copying the db to a new device, is kept once. Otherwise the
+// session that started later wins and the earlier one is cut off where it began, or dropped
+// when they started together. Every device works this out the same way, so the fixes they
+// log agree. Overlaps between sessions of the same device are left as they are
+func (s *syncer) resolveOverlaps(ctx context.Context) error {
+	type timed struct {
+		db.Session
+		start, end time.Time
+		uid        string
+	}
+	sessions, err := s.allSessions(ctx)
+	if err != nil {
+		return err
+	}
+	var list []timed
+	for _, ss := range sessions {
+		t := timed{Session: ss, uid: s.local[syncSession][ss.ID]}
+		if t.uid == "" {
+			if ss.EndTime.Valid {
+				// never synced, like sessions of a deleted task
+				continue
+			}
+			// running, it's this device's
+			t.uid = s.self.Device + "-"
+		}
+		if t.start, err = parseTimestamp(ss.StartTime); err != nil {
+			continue
+		}
+		if ss.EndTime.Valid {
+			if t.end, err = parseTimestamp(ss.EndTime.String); err != nil {
+				continue
+			}
+		}
+		list = append(list, t)
+	}
+	slices.SortFunc(list, func(a, b timed) int {
+		return cmp.Or(a.start.Compare(b.start), strings.Compare(a.uid, b.uid))
+	})
+	var kept []timed
+	for i, a := range list {
+		duplicate := false
+		for j := i - 1; j >= 0 && list[j].start.Equal(a.start); j-- {
+			b := list[j]
+			duplicate = duplicate || (b.TaskID == a.TaskID && b.EndTime.Valid && a.EndTime.Valid && syncOrigin(b.uid) != syncOrigin(a.uid))
+		}
+		if !duplicate {
+			kept = append(kept, a)
+			continue
+		}
+		if err := s.q.DeleteSession(ctx, a.ID); err != nil {
+			return err
+		}
+		s.stats.Removed++
+	}
+	list = kept
+	for i, a := range list {
+		// the running session is left alone until it ends
+		if !a.EndTime.Valid {
+			continue
+		}
+		// the first session of another device that starts before this one ends
+		var b *timed
+		for j := i + 1; j < len(list) && list[j].start.Before(a.end); j++ {
+			if syncOrigin(list[j].uid) != syncOrigin(a.uid) {
+				b = &list[j]
+				break
+			}
+		}
+		if b == nil {
+			continue
+		}
+		if !b.start.After(a.start) {
+			if err := s.q.DeleteSession(ctx, a.ID); err != nil {
+				return err
+			}
+			s.stats.Removed++
+			continue
+		}
+		err := s.q.UpdateSession(ctx, db.UpdateSessionParams{
+			StartTime:     a.StartTime,
+			EndTime:       sql.NullString{String: formatTimestamp(b.start), Valid: true},
+			TaskID:        a.TaskID,
+			OriginTaskID:  a.OriginTaskID,
+			EntropyReason: a.EntropyReason,
+			Kind:          a.Kind,
+			ID:            a.ID,
+		})
+		if err != nil {
+			return err
+		}
+		s.stats.Trimmed++
+	}
+	return nil
+}
+
+// keeps the ops for flushSyncLog, in the transaction of the sync
+func (s *syncer) queue(ctx context.Context) error {
+	for _, op := range s.ops {
+		line, err := json.Marshal(op)
+		if err != nil {
+			return err
+		}
+		if err := s.q.QueueSyncOp(ctx, db.QueueSyncOpParams{Device: op.Device, Clock: op.Clock, Line: string(line)}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// appends the ops waiting in sync_outbox to the logs in dir and empties it. Ops that already
+// made it into a log, before a crash kept the outbox from being emptied, are known by their
+// clock and left out, so every op is written once
+func flushSyncLog(ctx context.Context, q db.Querier, dir string) error {
+	pending, err := q.GetSyncOutbox(ctx)
+	if err != nil || len(pending) == 0 {
+		return err
+	}
+	// sorted by device, usually all this one's unless its device file was replaced since
+	for len(pending) > 0 {
+		n := 1
+		for n < len(pending) && pending[n].Device == pending[0].Device {
+			n++
+		}
+		if err := appendLog(dir, pending[:n]); err != nil {
+			return err
+		}
+		pending = pending[n:]
+	}
+	return q.ClearSyncOutbox(ctx)
+}
+
+// one write, so a peer never reads half of a sync's ops
+func appendLog(dir string, pending []db.SyncOutbox) error {
+	device := pending[0].Device
+	path := filepath.Join(dir, device+syncLogExt)
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	logged := lastClock(parseLog(filepath.Base(path), completeLines(data), device), device)
+	var lines []byte
+	for _, p := range pending {
+		if p.Clock > logged {
+			lines = append(lines, p.Line+"\n"...)
+		}
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		// a write cut short by a crash, peers skip the broken line and its ops follow again
+		lines = append([]byte{'\n'}, lines...)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(lines); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (s *syncer) saveDevices(ctx context.Context) error {
+	devices := append([]db.SyncDevice{s.self}, slices.Collect(maps.Values(s.peers))...)
+	for _, d := range devices {
+		if err := s.q.UpsertSyncDevice(ctx, db.UpsertSyncDeviceParams(d)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
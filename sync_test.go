@@ -0,0 +1,522 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	db "github.com/chee-zer/negentropy/database/sqlc"
+)
+
+// a device with a database of its own, syncing through dir
+type syncDevice struct {
+	id    string
+	dir   string
+	sqldb *sql.DB
+	q     *db.Queries
+}
+
+func newSyncDevice(t *testing.T, id, dir string) *syncDevice {
+	t.Helper()
+	sqldb, q := openTestDB(t)
+	return &syncDevice{id: id, dir: dir, sqldb: sqldb, q: q}
+}
+
+// a sync like runSync does it: the transaction and then the log
+func (d *syncDevice) sync(t *testing.T) syncStats {
+	t.Helper()
+	stats, err := d.syncWithoutLog(t)
+	if err != nil {
+		t.Fatalf("sync of %s: %v", d.id, err)
+	}
+	if err := flushSyncLog(context.Background(), d.q, d.dir); err != nil {
+		t.Fatal(err)
+	}
+	return stats
+}
+
+// a sync that stops after the commit, like a crash before the log was written
+func (d *syncDevice) syncWithoutLog(t *testing.T) (syncStats, error) {
+	t.Helper()
+	tx, err := d.sqldb.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	stats, err := syncFolder(context.Background(), d.q.WithTx(tx), d.dir, d.id)
+	if err != nil {
+		return stats, err
+	}
+	return stats, tx.Commit()
+}
+
+// the database of d becomes a copy of the one of other, under d's id
+func (d *syncDevice) copyOf(t *testing.T, other *syncDevice) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "copy.sqlite")
+	if _, err := other.sqldb.Exec("VACUUM INTO ?", path); err != nil {
+		t.Fatal(err)
+	}
+	sqldb, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqldb.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqldb.Close() })
+	d.sqldb, d.q = sqldb, db.New(sqldb)
+}
+
+func (d *syncDevice) taskID(t *testing.T, name string) int64 {
+	t.Helper()
+	tasks, err := d.q.GetTasks(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, task := range tasks {
+		if task.ID != 0 && task.Name == name {
+			return task.ID
+		}
+	}
+	created, err := d.q.CreateTask(context.Background(), db.CreateTaskParams{Name: name})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return created.ID
+}
+
+func (d *syncDevice) rename(t *testing.T, name, to string) {
+	t.Helper()
+	if err := d.q.UpdateTask(context.Background(), db.UpdateTaskParams{ID: d.taskID(t, name), Name: to}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// a finished session of task between two times of the day, like "09:00"
+func (d *syncDevice) session(t *testing.T, task, from, to string) {
+	t.Helper()
+	_, err := d.q.InsertSession(context.Background(), db.InsertSessionParams{
+		StartTime: formatTimestamp(syncTestTime(t, from)),
+		EndTime:   sql.NullString{String: formatTimestamp(syncTestTime(t, to)), Valid: true},
+		TaskID:    d.taskID(t, task),
+		Kind:      sessionWork,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (d *syncDevice) deleteSession(t *testing.T, from string) {
+	t.Helper()
+	for _, ss := range d.sessions(t) {
+		start, _ := parseTimestamp(ss.StartTime)
+		if start.Equal(syncTestTime(t, from)) {
+			if err := d.q.DeleteSession(context.Background(), ss.ID); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func (d *syncDevice) sessions(t *testing.T) []db.Session {
+	t.Helper()
+	sessions, err := d.q.GetSessionsInRange(context.Background(), db.GetSessionsInRangeParams{RangeStart: "", RangeEnd: "9999"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sessions
+}
+
+// tasks and sessions as sorted lines like "task code" and "code 09:00-10:00"
+func (d *syncDevice) state(t *testing.T) []string {
+	t.Helper()
+	tasks, err := d.q.GetTasks(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make(map[int64]string)
+	var lines []string
+	for _, task := range tasks {
+		names[task.ID] = task.Name
+		if task.ID != 0 {
+			lines = append(lines, "task "+task.Name)
+		}
+	}
+	for _, ss := range d.sessions(t) {
+		start, _ := parseTimestamp(ss.StartTime)
+		end, _ := parseTimestamp(ss.EndTime.String)
+		lines = append(lines, names[ss.TaskID]+" "+start.UTC().Format("15:04")+"-"+end.UTC().Format("15:04"))
+	}
+	slices.Sort(lines)
+	return lines
+}
+
+func syncTestTime(t *testing.T, clock string) time.Time {
+	t.Helper()
+	c, err := time.Parse("15:04", clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return time.Date(2025, 3, 12, c.Hour(), c.Minute(), 0, 0, time.UTC)
+}
+
+// the ops in the log of device
+func syncLog(t *testing.T, dir, device string) []syncOp {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, device+syncLogExt))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		t.Fatal(err)
+	}
+	return parseLog(device, completeLines(data), device)
+}
+
+func appendSyncLog(t *testing.T, dir, device, text string) {
+	t.Helper()
+	f, err := os.OpenFile(filepath.Join(dir, device+syncLogExt), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(text); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// a log line of device creating a task
+func taskOpLine(t *testing.T, device string, clock int64, uid, name string) string {
+	t.Helper()
+	data, _ := json.Marshal(syncedTask{Name: name})
+	line, err := json.Marshal(syncOp{Device: device, Clock: clock, Op: syncCreate, Kind: syncTask, UID: uid, Data: data})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(line) + "\n"
+}
+
+// both devices agree on want and have nothing left to send
+func checkConverged(t *testing.T, want []string, devices ...*syncDevice) {
+	t.Helper()
+	for _, d := range devices {
+		if got := d.state(t); !slices.Equal(got, want) {
+			t.Errorf("%s has\n%s\nwant\n%s", d.id, strings.Join(got, "\n"), strings.Join(want, "\n"))
+		}
+	}
+	for _, d := range devices {
+		if stats := d.sync(t); stats.Sent != 0 || stats.Applied != 0 {
+			t.Errorf("%s not settled: %+v", d.id, stats)
+		}
+	}
+}
+
+func TestSyncFolder(t *testing.T) {
+	tests := []struct {
+		name string
+		// changes made on the devices and the syncs in between, everything is synced a,b,a,b after
+		run  func(t *testing.T, a, b *syncDevice)
+		want []string
+	}{
+		{
+			name: "create and update",
+			run: func(t *testing.T, a, b *syncDevice) {
+				a.session(t, "code", "09:00", "10:00")
+				a.sync(t)
+				b.sync(t)
+				b.rename(t, "code", "coding")
+				b.session(t, "coding", "11:00", "12:00")
+			},
+			want: []string{"coding 09:00-10:00", "coding 11:00-12:00", "task coding"},
+		},
+		{
+			name: "later change wins",
+			run: func(t *testing.T, a, b *syncDevice) {
+				a.taskID(t, "code")
+				a.sync(t)
+				b.sync(t)
+				b.rename(t, "code", "b")
+				b.sync(t)
+				// seen b's rename, so this one comes after it
+				a.sync(t)
+				a.rename(t, "b", "a")
+			},
+			want: []string{"task a"},
+		},
+		{
+			name: "concurrent changes, a syncs first",
+			run: func(t *testing.T, a, b *syncDevice) {
+				a.taskID(t, "code")
+				a.sync(t)
+				b.sync(t)
+				a.rename(t, "code", "a")
+				b.rename(t, "code", "b")
+				a.sync(t)
+			},
+			// same clock, the larger device id wins
+			want: []string{"task b"},
+		},
+		{
+			name: "concurrent changes, b syncs first",
+			run: func(t *testing.T, a, b *syncDevice) {
+				a.taskID(t, "code")
+				a.sync(t)
+				b.sync(t)
+				a.rename(t, "code", "a")
+				b.rename(t, "code", "b")
+				b.sync(t)
+			},
+			want: []string{"task b"},
+		},
+		{
+			name: "delete",
+			run: func(t *testing.T, a, b *syncDevice) {
+				a.session(t, "code", "09:00", "10:00")
+				a.session(t, "code", "11:00", "12:00")
+				a.sync(t)
+				b.sync(t)
+				b.deleteSession(t, "09:00")
+			},
+			want: []string{"code 11:00-12:00", "task code"},
+		},
+		{
+			name: "same task created on both",
+			run: func(t *testing.T, a, b *syncDevice) {
+				a.session(t, "code", "09:00", "10:00")
+				b.session(t, "code", "11:00", "12:00")
+			},
+			want: []string{"code 09:00-10:00", "code 11:00-12:00", "task code"},
+		},
+		{
+			name: "overlap trimmed",
+			run: func(t *testing.T, a, b *syncDevice) {
+				a.session(t, "code", "09:00", "11:00")
+				b.session(t, "mail", "10:00", "12:00")
+			},
+			// the later one wins
+			want: []string{"code 09:00-10:00", "mail 10:00-12:00", "task code", "task mail"},
+		},
+		{
+			name: "overlap starting together",
+			run: func(t *testing.T, a, b *syncDevice) {
+				a.session(t, "code", "09:00", "10:00")
+				b.session(t, "mail", "09:00", "11:00")
+			},
+			// every device drops the same one
+			want: []string{"mail 09:00-11:00", "task code", "task mail"},
+		},
+		{
+			name: "overlap on one device",
+			run: func(t *testing.T, a, b *syncDevice) {
+				a.session(t, "code", "09:00", "11:00")
+				a.session(t, "mail", "10:00", "12:00")
+			},
+			want: []string{"code 09:00-11:00", "mail 10:00-12:00", "task code", "task mail"},
+		},
+		{
+			name: "copied database",
+			run: func(t *testing.T, a, b *syncDevice) {
+				a.session(t, "code", "09:00", "10:00")
+				a.sync(t)
+				a.rename(t, "code", "coding")
+				a.sync(t)
+				// not synced before the copy, both devices send it
+				a.session(t, "coding", "10:00", "11:00")
+				b.copyOf(t, a)
+				a.session(t, "coding", "13:00", "14:00")
+				b.session(t, "coding", "11:00", "12:00")
+				// has to win against the clock of a's rename that came with the copy
+				b.rename(t, "coding", "mine")
+			},
+			want: []string{"mine 09:00-10:00", "mine 10:00-11:00", "mine 11:00-12:00", "mine 13:00-14:00", "task mine"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			a, b := newSyncDevice(t, "a", dir), newSyncDevice(t, "b", dir)
+			tt.run(t, a, b)
+			a.sync(t)
+			b.sync(t)
+			a.sync(t)
+			b.sync(t)
+			checkConverged(t, tt.want, a, b)
+		})
+	}
+}
+
+func TestSyncPartialLine(t *testing.T) {
+	dir := t.TempDir()
+	a, b := newSyncDevice(t, "a", dir), newSyncDevice(t, "b", dir)
+	a.taskID(t, "code")
+	a.sync(t)
+
+	// a write of a that the shared folder only delivered half of so far
+	line := taskOpLine(t, "a", 100, "a-half", "half")
+	appendSyncLog(t, dir, "a", line[:len(line)/2])
+	b.sync(t)
+	if got, want := b.state(t), []string{"task code"}; !slices.Equal(got, want) {
+		t.Fatalf("with half a line b has %v, want %v", got, want)
+	}
+	appendSyncLog(t, dir, "a", line[len(line)/2:])
+	if stats := b.sync(t); stats.Received != 1 || stats.Applied != 1 {
+		t.Errorf("the rest of the line: %+v", stats)
+	}
+	if got, want := b.state(t), []string{"task code", "task half"}; !slices.Equal(got, want) {
+		t.Errorf("b has %v, want %v", got, want)
+	}
+}
+
+func TestSyncReplacedLog(t *testing.T) {
+	dir := t.TempDir()
+	a, b := newSyncDevice(t, "a", dir), newSyncDevice(t, "b", dir)
+	for _, name := range []string{"code", "mail", "read"} {
+		a.taskID(t, name)
+	}
+	a.sync(t)
+	b.sync(t)
+
+	// restored from the folder's history, say, and shorter than what b read of it
+	if err := os.WriteFile(filepath.Join(dir, "a"+syncLogExt), []byte(taskOpLine(t, "a", 100, "a-fresh", "fresh")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	b.sync(t)
+	if got, want := b.state(t), []string{"task code", "task fresh", "task mail", "task read"}; !slices.Equal(got, want) {
+		t.Errorf("b has %v, want %v", got, want)
+	}
+}
+
+func TestSyncCrash(t *testing.T) {
+	tests := []struct {
+		name  string
+		crash func(t *testing.T, a *syncDevice)
+	}{
+		{
+			name: "before the log was written",
+			crash: func(t *testing.T, a *syncDevice) {
+				if _, err := a.syncWithoutLog(t); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name: "before the outbox was emptied",
+			crash: func(t *testing.T, a *syncDevice) {
+				if _, err := a.syncWithoutLog(t); err != nil {
+					t.Fatal(err)
+				}
+				tx, err := a.sqldb.Begin()
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer tx.Rollback()
+				if err := flushSyncLog(context.Background(), a.q.WithTx(tx), a.dir); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name: "in the middle of the write",
+			crash: func(t *testing.T, a *syncDevice) {
+				if _, err := a.syncWithoutLog(t); err != nil {
+					t.Fatal(err)
+				}
+				pending, err := a.q.GetSyncOutbox(context.Background())
+				if err != nil {
+					t.Fatal(err)
+				}
+				appendSyncLog(t, a.dir, "a", pending[0].Line[:10])
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			a, b := newSyncDevice(t, "a", dir), newSyncDevice(t, "b", dir)
+			a.session(t, "code", "09:00", "10:00")
+			tt.crash(t, a)
+			a.sync(t)
+			b.sync(t)
+
+			if ops := syncLog(t, dir, "a"); len(ops) != 2 {
+				t.Errorf("log of a has %+v, want the task and the session once", ops)
+			}
+			checkConverged(t, []string{"code 09:00-10:00", "task code"}, a, b)
+		})
+	}
+}
+
+func TestSyncTaskArrivesLater(t *testing.T) {
+	dir := t.TempDir()
+	a, b, c := newSyncDevice(t, "a", dir), newSyncDevice(t, "b", dir), newSyncDevice(t, "c", dir)
+	c.taskID(t, "design")
+	c.sync(t)
+	a.sync(t)
+	a.session(t, "design", "09:00", "10:00")
+	a.taskID(t, "code")
+	a.sync(t)
+
+	// the log of c, with the task, hasn't reached b's copy of the folder yet
+	cLog := filepath.Join(dir, "c"+syncLogExt)
+	hidden := filepath.Join(t.TempDir(), "c"+syncLogExt)
+	if err := os.Rename(cLog, hidden); err != nil {
+		t.Fatal(err)
+	}
+	if stats := b.sync(t); stats.Skipped != 1 {
+		t.Errorf("without the task: %+v", stats)
+	}
+	if got, want := b.state(t), []string{"task code"}; !slices.Equal(got, want) {
+		t.Errorf("without the task b has %v, want %v", got, want)
+	}
+	if err := os.Rename(hidden, cLog); err != nil {
+		t.Fatal(err)
+	}
+	if stats := b.sync(t); stats.Skipped != 0 {
+		t.Errorf("with the task: %+v", stats)
+	}
+	c.sync(t)
+	checkConverged(t, []string{"design 09:00-10:00", "task code", "task design"}, a, b, c)
+}
+
+func TestSyncRestoredDatabase(t *testing.T) {
+	dir := t.TempDir()
+	a := newSyncDevice(t, "a", dir)
+	a.session(t, "code", "09:00", "10:00")
+	a.sync(t)
+	backup := newSyncDevice(t, "a", dir)
+	backup.copyOf(t, a)
+	a.session(t, "code", "11:00", "12:00")
+	a.sync(t)
+
+	// the backup restored on the same machine, with the same device file
+	if _, err := backup.syncWithoutLog(t); !errors.Is(err, errSyncLogAhead) {
+		t.Fatalf("sync of a restored database: %v, want %v", err, errSyncLogAhead)
+	}
+	if got, want := backup.state(t), []string{"code 09:00-10:00", "task code"}; !slices.Equal(got, want) {
+		t.Errorf("refused sync changed the database: %v", got)
+	}
+	// with the device file deleted it gets back what it missed
+	backup.id = "c"
+	backup.sync(t)
+	checkConverged(t, []string{"code 09:00-10:00", "code 11:00-12:00", "task code"}, a, backup)
+}
+
+func TestSyncDeviceID(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "negentropy", "sync-device")
+	id, err := syncDeviceID(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, err := syncDeviceID(path); err != nil || again != id {
+		t.Errorf("second read = %q, %v, want %q", again, err, id)
+	}
+	if err := os.WriteFile(path, []byte("my-laptop\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := syncDeviceID(path); err == nil {
+		t.Error("an id with a dash was accepted")
+	}
+}
@@ -11,6 +11,9 @@ type TabModel struct {
 	ActiveTabIndex int
 	//TasksWithProgress map[int]map[string]float32
 	Tasks []db.Task
+	// daily target streaks per task id, tasks without a target have none
+	Streaks map[int64]streak
+	Overall streak
 }
 
 // msg for switching tabs/tasks.
@@ -94,13 +97,16 @@ func (m TabModel) Update(msg tea.Msg) (TabModel, tea.Cmd) {
 }
 
 func (m TabModel) View() string {
-	output := ""
+	output := "overall " + m.Overall.String()
 	for i, task := range m.Tasks {
 		marker := " "
 		if i == m.ActiveTabIndex {
 			marker = ">"
 		}
 		output += fmt.Sprintf("\n%s%d: %s", marker, i, task.Name)
+		if s, ok := m.Streaks[task.ID]; ok && s.Best > 0 {
+			output += "  " + s.String()
+		}
 	}
 	return output
 }
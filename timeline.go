@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	db "github.com/chee-zer/negentropy/database/sqlc"
+	"github.com/chee-zer/negentropy/gitlog"
+)
+
+const (
+	timelineCellsPerHour = 4 // 15 minutes per cell
+	timelineLabelWidth   = 14
+	// commits listed under the timeline, the row above still marks all of them
+	timelineMaxCommits = 8
+)
+
+var hexColor = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// used for tasks without a usable color_hex, picked by task id
+var fallbackColors = []string{"#5FAFFF", "#87D787", "#FFD75F", "#D787FF", "#5FD7D7", "#FFAF5F"}
+
+func taskColor(t db.Task) lipgloss.Color {
+	if t.ColorHex.Valid && hexColor.MatchString(t.ColorHex.String) {
+		return lipgloss.Color(t.ColorHex.String)
+	}
+	return lipgloss.Color(fallbackColors[int(t.ID)%len(fallbackColors)])
+}
+
+type timelineRow struct {
+	label string
+	color lipgloss.Color
+	glyph string
+	spans []span
+	total time.Duration
+}
+
+// one row per task worked on that day, plus entropy, breaks and commits. Sessions crossing
+// midnight only show the part inside the day since loadSpans clips them
+func renderTimeline(spans []span, tasks map[int64]db.Task, commits []gitlog.Commit, day time.Time, now time.Time) string {
+	rows := make(map[string]*timelineRow)
+	var order []string
+	rowFor := func(key string, mk func() *timelineRow) *timelineRow {
+		if r, ok := rows[key]; ok {
+			return r
+		}
+		rows[key] = mk()
+		order = append(order, key)
+		return rows[key]
+	}
+	for _, s := range spans {
+		var r *timelineRow
+		switch {
+		case s.isEntropy():
+			r = rowFor("~entropy", func() *timelineRow {
+				return &timelineRow{label: "entropy", color: taskColor(tasks[entropyTaskID]), glyph: "x"}
+			})
+		case s.isBreak():
+			r = rowFor("~break", func() *timelineRow {
+				return &timelineRow{label: "breaks", color: lipgloss.Color("#808080"), glyph: "░"}
+			})
+		default:
+			t, ok := tasks[s.TaskID]
+			if !ok {
+				t = db.Task{ID: s.TaskID, Name: fmt.Sprintf("#%d", s.TaskID)}
+			}
+			r = rowFor(fmt.Sprintf("%08d", s.TaskID), func() *timelineRow {
+				return &timelineRow{label: t.Name, color: taskColor(t), glyph: "█"}
+			})
+		}
+		r.spans = append(r.spans, s)
+		r.total += s.duration()
+	}
+	// tasks by id first, entropy and breaks at the bottom
+	sort.Strings(order)
+
+	// 23 or 25 hours on the days the clocks change
+	cell := time.Hour / timelineCellsPerHour
+	cells := int(day.AddDate(0, 0, 1).Sub(day) / cell)
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n\n", day.Format("Monday, 2006-01-02"))
+
+	ruler := []byte(strings.Repeat(" ", cells))
+	for h := 0; h < cells/timelineCellsPerHour; h += 3 {
+		copy(ruler[h*timelineCellsPerHour:], day.Add(time.Duration(h)*time.Hour).Format("15"))
+	}
+	fmt.Fprintf(&b, "%*s %s\n", timelineLabelWidth, "", string(ruler))
+
+	if len(order) == 0 {
+		fmt.Fprintf(&b, "%*s %s\n", timelineLabelWidth, "", "nothing tracked")
+	}
+	for _, k := range order {
+		r := rows[k]
+		style := lipgloss.NewStyle().Foreground(r.color)
+		line := make([]string, cells)
+		for i := range line {
+			line[i] = "·"
+			if i%timelineCellsPerHour != 0 {
+				line[i] = " "
+			}
+		}
+		for _, s := range r.spans {
+			first := int(s.Start.Sub(day) / cell)
+			last := int((s.End.Sub(day) - 1) / cell)
+			for i := max(first, 0); i <= min(last, cells-1); i++ {
+				line[i] = style.Render(r.glyph)
+			}
+		}
+		// cut by display width, task names can have wide characters
+		label := ansi.Truncate(r.label, timelineLabelWidth-1, "")
+		label += strings.Repeat(" ", timelineLabelWidth-ansi.StringWidth(label))
+		fmt.Fprintf(&b, "%s %s %s\n", label, strings.Join(line, ""), formatDuration(r.total))
+	}
+	if len(commits) > 0 {
+		line := []byte(strings.Repeat(" ", cells))
+		for _, c := range commits {
+			if i := int(c.Time.Sub(day) / cell); i >= 0 && i < cells {
+				line[i] = '|'
+			}
+		}
+		fmt.Fprintf(&b, "%-*s %s %d\n", timelineLabelWidth, "commits", string(line), len(commits))
+	}
+
+	if now.After(day) && now.Before(day.AddDate(0, 0, 1)) {
+		pos := int(now.Sub(day) / cell)
+		fmt.Fprintf(&b, "%*s %s^ now\n", timelineLabelWidth, "", strings.Repeat(" ", pos))
+	}
+
+	if len(commits) > 0 {
+		b.WriteString("\n")
+	}
+	for i, c := range commits {
+		if i == timelineMaxCommits {
+			fmt.Fprintf(&b, "%*s +%d more, see `negentropy commits`\n", timelineLabelWidth, "", len(commits)-i)
+			break
+		}
+		fmt.Fprintf(&b, "%*s %s\n", timelineLabelWidth, "", commitLine(c, "15:04")+commitTask(spans, tasks, c))
+	}
+	return b.String()
+}
+
+// commits of a timeline day. git log can take a while on big repos, so they're loaded off the
+// update loop and the timeline is drawn again once they're here
+type timelineCommitsMsg struct {
+	day     time.Time
+	commits []gitlog.Commit
+}
+
+func (m model) openTimeline() (model, tea.Cmd) {
+	if m.timelineDay.IsZero() {
+		m.timelineDay = startOfDay(time.Now())
+	}
+	m.timelineCommits, m.timelineLoading = nil, len(m.gitRepos) > 0
+	m = m.renderTimeline()
+	if m.view != timelineView || !m.timelineLoading {
+		return m, nil
+	}
+	repos, author, day := m.gitRepos, m.gitAuthor, m.timelineDay
+	return m, func() tea.Msg {
+		return timelineCommitsMsg{day: day, commits: loadCommits(context.Background(), repos, author, day, day.AddDate(0, 0, 1))}
+	}
+}
+
+func (m model) renderTimeline() model {
+	spans, err := loadSpans(context.Background(), m.db, m.timelineDay, m.timelineDay.AddDate(0, 0, 1))
+	if err != nil {
+		m.StatusQuote = "Couldn't load timeline: " + err.Error()
+		return m
+	}
+	m.timeline = renderTimeline(spans, m.tasks, m.timelineCommits, m.timelineDay, time.Now())
+	m.view = timelineView
+	return m
+}
+
+// commits for a day that was left or a timeline that was closed in the meantime are dropped
+func (m model) timelineCommitsLoaded(msg timelineCommitsMsg) (tea.Model, tea.Cmd) {
+	if m.view != timelineView || !msg.day.Equal(m.timelineDay) {
+		return m, nil
+	}
+	m.timelineCommits, m.timelineLoading = msg.commits, false
+	return m.renderTimeline(), nil
+}
+
+// ←/→ move a day
+func (m model) updateTimeline(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, m.keymap.ToggleTimeline), msg.Type == tea.KeyEsc:
+		m.view = mainView
+		return m, nil
+	case key.Matches(msg, m.keymap.GoLeft):
+		m.timelineDay = m.timelineDay.AddDate(0, 0, -1)
+	case key.Matches(msg, m.keymap.GoRight):
+		m.timelineDay = m.timelineDay.AddDate(0, 0, 1)
+	default:
+		return m, nil
+	}
+	return m.openTimeline()
+}
+
+func (m model) timelineViewString() string {
+	timeline := m.timeline
+	if m.timelineLoading {
+		timeline += fmt.Sprintf("%*s loading commits…\n", timelineLabelWidth, "")
+	}
+	return fmt.Sprintf("\n\n%s\n  %s\n\n  ←/→ day  esc back\n", timeline, m.Timer.View())
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/x/ansi"
+	db "github.com/chee-zer/negentropy/database/sqlc"
+)
+
+func TestRenderTimeline(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip(err)
+	}
+	tasks := map[int64]db.Task{
+		1: {ID: 1, Name: "code"},
+		2: {ID: 2, Name: "日本語のドキュメントを書く"},
+	}
+	for _, tt := range []struct {
+		name  string
+		day   time.Time
+		cells int
+	}{
+		{name: "clocks go forward", day: time.Date(2026, 3, 29, 0, 0, 0, 0, berlin), cells: 23 * timelineCellsPerHour},
+		{name: "clocks go back", day: time.Date(2026, 10, 25, 0, 0, 0, 0, berlin), cells: 25 * timelineCellsPerHour},
+		{name: "normal day", day: time.Date(2026, 10, 26, 0, 0, 0, 0, berlin), cells: 24 * timelineCellsPerHour},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			// the last hour of the day
+			end := tt.day.AddDate(0, 0, 1)
+			spans := []span{
+				{TaskID: 1, Start: end.Add(-time.Hour), End: end},
+				{TaskID: 2, Start: end.Add(-time.Hour), End: end},
+			}
+			lines := strings.Split(ansi.Strip(renderTimeline(spans, tasks, nil, tt.day, tt.day)), "\n")
+			rows := lines[3:5]
+			for _, row := range rows {
+				// the label column, the cells and the total
+				grid, ok := strings.CutSuffix(row, " 1h00m")
+				if w := ansi.StringWidth(grid); !ok || w != timelineLabelWidth+1+tt.cells {
+					t.Errorf("%d wide, want %d cells after the label: %q", w, tt.cells, row)
+				}
+				if !strings.HasSuffix(grid, strings.Repeat("█", timelineCellsPerHour)) {
+					t.Errorf("the last hour isn't tracked: %q", row)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/chee-zer/negentropy/daemon"
+	db "github.com/chee-zer/negentropy/database/sqlc"
+	"github.com/chee-zer/negentropy/stopwatch"
+)
+
+// how often the UI checks whether the timer was started or stopped somewhere else
+// (another client of the daemon, or the start/stop commands)
+const timerSyncInterval = 2 * time.Second
+
+// the timer straight on the database. The daemon serves this, the UI and the commands use it
+// directly when no daemon is running. Session events are emitted here, so they fire once
+// no matter which client asked
+type localTimer struct {
+	q      db.Querier
+	events *dispatcher
+}
+
+var _ daemon.Timer = localTimer{}
+
+func (t localTimer) Status(ctx context.Context) (daemon.Status, error) {
+	session, err := t.q.GetRunningSession(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return daemon.Status{}, nil
+	}
+	if err != nil {
+		return daemon.Status{}, err
+	}
+	return daemon.Status{Session: &session}, nil
+}
+
+func (t localTimer) Start(ctx context.Context, taskID int64, at time.Time) (daemon.Status, error) {
+	status, err := t.Status(ctx)
+	if err != nil {
+		return status, err
+	}
+	if status.Running() {
+		return status, daemon.ErrRunning
+	}
+	session, err := t.q.StartSession(ctx, db.StartSessionParams{
+		StartTime: formatTimestamp(at),
+		TaskID:    taskID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		// another process started one since the check above
+		status, err := t.Status(ctx)
+		if err != nil {
+			return status, err
+		}
+		return status, daemon.ErrRunning
+	}
+	if err != nil {
+		return daemon.Status{}, err
+	}
+	t.events.emit(sessionEvent(ctx, t.q, eventSessionStart, session, at))
+	return daemon.Status{Session: &session}, nil
+}
+
+func (t localTimer) Stop(ctx context.Context, at time.Time) (daemon.Status, error) {
+	status, err := t.Status(ctx)
+	if err != nil {
+		return status, err
+	}
+	if !status.Running() {
+		return status, daemon.ErrNotRunning
+	}
+	session, err := t.q.EndSession(ctx, db.EndSessionParams{
+		EndTime: sql.NullString{String: formatTimestamp(at), Valid: true},
+		TaskID:  status.Session.TaskID,
+	})
+	if err != nil {
+		return status, err
+	}
+	t.events.emit(sessionEvent(ctx, t.q, eventSessionStop, session, at))
+	return daemon.Status{}, nil
+}
+
+func (t localTimer) Reset(ctx context.Context, at time.Time, reason string) (daemon.Status, error) {
+	status, err := t.Status(ctx)
+	if err != nil {
+		return status, err
+	}
+	if !status.Running() {
+		return status, daemon.ErrNotRunning
+	}
+	session, err := t.q.EndSessionAsEntropy(ctx, db.EndSessionAsEntropyParams{
+		EndTime:       sql.NullString{String: formatTimestamp(at), Valid: true},
+		EntropyReason: sql.NullString{String: reason, Valid: reason != ""},
+		TaskID:        status.Session.TaskID,
+	})
+	if err != nil {
+		return status, err
+	}
+	t.events.emit(sessionEvent(ctx, t.q, eventSessionEntropy, session, at))
+	return daemon.Status{}, nil
+}
+
+// the daemon's timer if one is running, otherwise the database. attached tells which one it is
+func connectTimer(q db.Querier, socket string, events *dispatcher) (timer daemon.Timer, attached bool) {
+	client, err := daemon.Dial(socket)
+	if err != nil {
+		return localTimer{q: q, events: events}, false
+	}
+	return client, true
+}
+
+type timerSyncMsg struct {
+	status daemon.Status
+	err    error
+	at     time.Time
+}
+
+func (m model) timerSyncCmd() tea.Cmd {
+	timer := m.timer
+	return tea.Tick(timerSyncInterval, func(time.Time) tea.Msg {
+		at := time.Now()
+		status, err := timer.Status(context.Background())
+		return timerSyncMsg{status: status, err: err, at: at}
+	})
+}
+
+// picks up sessions started or stopped by someone else. Skipped while the user is in the
+// middle of a prompt, the next sync catches up
+func (m model) syncTimer(msg timerSyncMsg) (tea.Model, tea.Cmd) {
+	if msg.err != nil {
+		log.Printf("timer sync: %v", msg.err)
+		return m, m.timerSyncCmd()
+	}
+	// a status from before our own last start/stop is already stale
+	if (m.state != TimerRunning && m.state != TimerNotRunning) || msg.at.Before(m.timerChangedAt) {
+		return m, m.timerSyncCmd()
+	}
+	running := m.state == TimerRunning && m.CurrentSession != nil
+	switch {
+	case msg.status.Running() && (!running || m.CurrentSession.ID != msg.status.Session.ID):
+		m = m.resume(*msg.status.Session)
+		m.StatusQuote = "Session started elsewhere: " + m.tasks[m.ActiveTaskId].Name
+		return m, tea.Batch(m.Timer.StartCmd(), m.idleCheckCmd(), m.timerSyncCmd())
+	case !msg.status.Running() && running:
+		m.state = TimerNotRunning
+		m.CurrentSession = nil
+		m.StatusQuote = "Session ended elsewhere"
+		return m.refreshToday(), tea.Batch(m.Timer.StopCmd(), m.timerSyncCmd())
+	}
+	return m, m.timerSyncCmd()
+}
+
+// shows a session that is already running, with the time it has been running for
+func (m model) resume(session db.Session) model {
+	start, err := parseTimestamp(session.StartTime)
+	if err != nil {
+		log.Printf("resume session %d: %v", session.ID, err)
+		start = time.Now()
+	}
+	m = m.selectTask(session.TaskID)
+	m.Timer = stopwatch.NewTimerRunning(m.tasks[session.TaskID].Name)
+	m.Timer.SessionTime = time.Since(start).Truncate(time.Second)
+	m.CurrentSession = &session
+	m.state = TimerRunning
+	m.lastIdleCheck = time.Now()
+	return m.refreshToday()
+}
+
+// makes id the active task and moves the tabs to it
+func (m model) selectTask(id int64) model {
+	m.ActiveTaskId = id
+	for i, t := range m.tabs.Tasks {
+		if t.ID == id {
+			m.tabs.ActiveTabIndex = i
+		}
+	}
+	return m
+}
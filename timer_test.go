@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/chee-zer/negentropy/daemon"
+	db "github.com/chee-zer/negentropy/database/sqlc"
+)
+
+func TestStartWhileRunning(t *testing.T) {
+	_, q := openTestDB(t)
+	ctx := context.Background()
+	timer := localTimer{q: q, events: &dispatcher{}}
+	first, err := timer.Start(ctx, 0, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// what a second process gets when it checked before the first one inserted
+	if _, err := q.StartSession(ctx, db.StartSessionParams{StartTime: formatTimestamp(time.Now()), TaskID: 0}); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("second running session inserted: %v", err)
+	}
+	status, err := timer.Start(ctx, 0, time.Now())
+	if !errors.Is(err, daemon.ErrRunning) || status.Session == nil || status.Session.ID != first.Session.ID {
+		t.Errorf("start while running = %+v, %v", status, err)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	db "github.com/chee-zer/negentropy/database/sqlc"
+)
+
+// tracked time for the current day, the running session is added live so this
+// only has to be reloaded when a session starts or ends
+type todayStats struct {
+	day         time.Time
+	closed      map[int64]time.Duration
+	runningTask int64
+	runningFrom time.Time // zero when nothing is running
+}
+
+func loadTodayStats(ctx context.Context, q db.Querier, now time.Time) (todayStats, error) {
+	day := startOfDay(now)
+	stats := todayStats{day: day, closed: make(map[int64]time.Duration)}
+	spans, err := loadSpans(ctx, q, day, day.AddDate(0, 0, 1))
+	if err != nil {
+		return stats, err
+	}
+	for _, s := range spans {
+		if s.isEntropy() || s.isBreak() {
+			continue
+		}
+		if s.Running {
+			stats.runningTask = s.TaskID
+			stats.runningFrom = s.Start
+			continue
+		}
+		stats.closed[s.TaskID] += s.duration()
+	}
+	return stats, nil
+}
+
+func (t todayStats) running(now time.Time) time.Duration {
+	if t.runningFrom.IsZero() || now.Before(t.runningFrom) {
+		return 0
+	}
+	return now.Sub(t.runningFrom)
+}
+
+func (t todayStats) total(now time.Time) time.Duration {
+	var sum time.Duration
+	for _, d := range t.closed {
+		sum += d
+	}
+	return sum + t.running(now)
+}
+
+func (t todayStats) task(id int64, now time.Time) time.Duration {
+	d := t.closed[id]
+	if t.runningTask == id {
+		d += t.running(now)
+	}
+	return d
+}
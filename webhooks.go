@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	db "github.com/chee-zer/negentropy/database/sqlc"
+)
+
+const (
+	// one delivery attempt, also how long a claimed delivery is left alone by other processes
+	webhookTimeout = 10 * time.Second
+	// first retry after this long, doubling up to webhookMaxBackoff
+	webhookBackoff    = 30 * time.Second
+	webhookMaxBackoff = time.Hour
+	// about a day of retries at the max backoff before a delivery is marked failed
+	webhookMaxAttempts = 30
+	// how often long running processes look for deliveries that are due again
+	webhookRetryEvery = 30 * time.Second
+	// a short command waits at most this long for its deliveries, the rest stays in the outbox
+	webhookWaitFor = 5 * time.Second
+)
+
+// events posted to a webhook that doesn't list any
+var defaultWebhookEvents = []string{eventSessionStart, eventSessionStop, eventSessionEntropy}
+
+type webhookConfig struct {
+	URL    string   `json:"url"`
+	Secret string   `json:"secret"` // signs the body, see the README
+	Events []string `json:"events"` // empty means session start, stop and entropy
+}
+
+func (c webhookConfig) wants(name string) bool {
+	if len(c.Events) == 0 {
+		return slices.Contains(defaultWebhookEvents, name)
+	}
+	return slices.Contains(c.Events, name)
+}
+
+func validateWebhooks(hooks []webhookConfig) error {
+	for _, h := range hooks {
+		u, err := url.Parse(h.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("webhook url %q isn't an http(s) url", h.URL)
+		}
+		for _, name := range h.Events {
+			if !slices.Contains(eventNames, name) {
+				return fmt.Errorf("webhook %s: unknown event %q", h.URL, name)
+			}
+		}
+	}
+	return nil
+}
+
+// posts events to the configured urls. Every delivery goes through the webhook_outbox table
+// first, so events raised while offline are sent once the url is reachable again, by whichever
+// negentropy process flushes the outbox next
+type webhookSender struct {
+	q      db.Querier
+	hooks  []webhookConfig
+	client *http.Client
+	// one flush at a time per process, claims keep other processes off the same rows
+	flushMu sync.Mutex
+	wg      sync.WaitGroup
+}
+
+func newWebhookSender(q db.Querier, hooks []webhookConfig) *webhookSender {
+	return &webhookSender{q: q, hooks: hooks, client: &http.Client{Timeout: webhookTimeout}}
+}
+
+func (s *webhookSender) handle(ev event) {
+	var targets []string
+	for _, h := range s.hooks {
+		if h.wants(ev.Name) {
+			targets = append(targets, h.URL)
+		}
+	}
+	if len(targets) == 0 {
+		return
+	}
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		log.Printf("webhook %s: %v", ev.Name, err)
+		return
+	}
+	now := formatTimestamp(time.Now())
+	for _, u := range targets {
+		_, err := s.q.EnqueueWebhook(context.Background(), db.EnqueueWebhookParams{
+			Url:           u,
+			Event:         ev.Name,
+			Payload:       string(payload),
+			NextAttemptAt: now,
+			CreatedAt:     now,
+		})
+		if err != nil {
+			log.Printf("webhook %s: couldn't queue for %s: %v", ev.Name, u, err)
+		}
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.flush(context.Background(), time.Now())
+	}()
+}
+
+func (s *webhookSender) wait() {
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(webhookWaitFor):
+		log.Printf("webhooks still sending, the rest is retried later")
+	}
+}
+
+// flushes the outbox every webhookRetryEvery until ctx is done, for the TUI, daemon and serve
+func (s *webhookSender) retry(ctx context.Context) {
+	if len(s.hooks) == 0 {
+		return
+	}
+	t := time.NewTicker(webhookRetryEvery)
+	defer t.Stop()
+	for {
+		s.flush(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// sends every delivery that's due at now
+func (s *webhookSender) flush(ctx context.Context, now time.Time) {
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+	due, err := s.q.GetDueWebhooks(ctx, db.GetDueWebhooksParams{Now: formatTimestamp(now), MaxRows: 100})
+	if err != nil {
+		log.Printf("webhooks: %v", err)
+		return
+	}
+	for _, d := range due {
+		if ctx.Err() != nil {
+			return
+		}
+		claimed, err := s.q.ClaimWebhook(ctx, db.ClaimWebhookParams{
+			LeaseUntil:    formatTimestamp(now.Add(3 * webhookTimeout)),
+			ID:            d.ID,
+			NextAttemptAt: d.NextAttemptAt,
+		})
+		if err != nil {
+			log.Printf("webhook %d: %v", d.ID, err)
+			continue
+		}
+		if claimed == 0 {
+			// another process got to it first
+			continue
+		}
+		s.deliver(ctx, d)
+	}
+}
+
+func (s *webhookSender) deliver(ctx context.Context, d db.WebhookOutbox) {
+	hook, ok := s.config(d.Url)
+	if !ok {
+		// removed from the config since it was queued, nobody wants it anymore
+		if err := s.q.DeleteWebhook(ctx, d.ID); err != nil {
+			log.Printf("webhook %d: %v", d.ID, err)
+		}
+		return
+	}
+	permanent, err := s.post(ctx, hook, d)
+	if err == nil {
+		if err := s.q.DeleteWebhook(ctx, d.ID); err != nil {
+			log.Printf("webhook %d: sent but couldn't remove it from the outbox: %v", d.ID, err)
+		}
+		return
+	}
+	lastErr := sql.NullString{String: err.Error(), Valid: true}
+	now := time.Now()
+	if permanent || d.Attempts+1 >= webhookMaxAttempts {
+		log.Printf("webhook %s to %s failed for good: %v", d.Event, d.Url, err)
+		err = s.q.FailWebhook(ctx, db.FailWebhookParams{
+			FailedAt:  sql.NullString{String: formatTimestamp(now), Valid: true},
+			LastError: lastErr,
+			ID:        d.ID,
+		})
+	} else {
+		log.Printf("webhook %s to %s failed, retrying: %v", d.Event, d.Url, err)
+		err = s.q.RetryWebhook(ctx, db.RetryWebhookParams{
+			NextAttemptAt: formatTimestamp(now.Add(webhookRetryAfter(d.Attempts))),
+			LastError:     lastErr,
+			ID:            d.ID,
+		})
+	}
+	if err != nil {
+		log.Printf("webhook %d: %v", d.ID, err)
+	}
+}
+
+// posts one delivery, permanent is set when retrying won't help
+func (s *webhookSender) post(ctx context.Context, hook webhookConfig, d db.WebhookOutbox) (permanent bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.Url, bytes.NewReader([]byte(d.Payload)))
+	if err != nil {
+		return true, err
+	}
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "negentropy")
+	req.Header.Set("X-Negentropy-Event", d.Event)
+	req.Header.Set("X-Negentropy-Delivery", strconv.FormatInt(d.ID, 10))
+	req.Header.Set("X-Negentropy-Timestamp", ts)
+	if hook.Secret != "" {
+		req.Header.Set("X-Negentropy-Signature", "sha256="+signWebhook(hook.Secret, ts, []byte(d.Payload)))
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	// the receiver rejected the payload itself, sending it again gets the same answer
+	permanent = resp.StatusCode >= 400 && resp.StatusCode < 500 &&
+		resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests
+	return permanent, fmt.Errorf("%s", resp.Status)
+}
+
+func (s *webhookSender) config(u string) (webhookConfig, bool) {
+	for _, h := range s.hooks {
+		if h.URL == u {
+			return h, true
+		}
+	}
+	return webhookConfig{}, false
+}
+
+// hex HMAC-SHA256 of "<timestamp>.<body>", the timestamp is signed too so old deliveries can't be replayed
+func signWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// backoff before the next attempt after attempts failed ones
+func webhookRetryAfter(attempts int64) time.Duration {
+	d := webhookBackoff
+	for i := int64(0); i < attempts && d < webhookMaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, webhookMaxBackoff)
+}
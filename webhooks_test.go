@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	db "github.com/chee-zer/negentropy/database/sqlc"
+)
+
+// a webhook receiver answering with the statuses in order, 200 once they're used up
+type receiver struct {
+	*httptest.Server
+	mu       sync.Mutex
+	statuses []int
+	got      []receivedHook
+}
+
+type receivedHook struct {
+	header http.Header
+	body   []byte
+}
+
+func newReceiver(t *testing.T, statuses ...int) *receiver {
+	r := &receiver{statuses: statuses}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.got = append(r.got, receivedHook{header: req.Header, body: body})
+		status := http.StatusOK
+		if len(r.statuses) > 0 {
+			status, r.statuses = r.statuses[0], r.statuses[1:]
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *receiver) received() []receivedHook {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]receivedHook(nil), r.got...)
+}
+
+// every delivery still in the outbox, failed ones too
+func outbox(t *testing.T, sqldb *sql.DB) []db.WebhookOutbox {
+	t.Helper()
+	rows, err := sqldb.Query("SELECT id, url, event, payload, attempts, next_attempt_at, last_error, failed_at, created_at FROM webhook_outbox ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var all []db.WebhookOutbox
+	for rows.Next() {
+		var d db.WebhookOutbox
+		if err := rows.Scan(&d.ID, &d.Url, &d.Event, &d.Payload, &d.Attempts, &d.NextAttemptAt, &d.LastError, &d.FailedAt, &d.CreatedAt); err != nil {
+			t.Fatal(err)
+		}
+		all = append(all, d)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return all
+}
+
+func stopEvent() event {
+	end := time.Date(2025, 3, 12, 10, 0, 0, 0, time.UTC)
+	return event{
+		Name:    eventSessionStop,
+		Time:    end,
+		Task:    &eventTask{ID: 1, Name: "code"},
+		Session: &eventSession{ID: 7, TaskID: 1, Start: end.Add(-time.Hour), End: &end, DurationSeconds: 3600},
+	}
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	sqldb, q := openTestDB(t)
+	r := newReceiver(t)
+	s := newWebhookSender(q, []webhookConfig{
+		{URL: r.URL + "/signed", Secret: "s3cret"},
+		{URL: r.URL + "/plain"},
+		{URL: r.URL + "/starts", Events: []string{eventSessionStart}},
+	})
+
+	s.handle(stopEvent())
+	s.wait()
+
+	got := r.received()
+	if len(got) != 2 {
+		t.Fatalf("%d deliveries, want 2", len(got))
+	}
+	want, _ := json.Marshal(stopEvent())
+	for _, h := range got {
+		if string(h.body) != string(want) {
+			t.Errorf("body = %s, want %s", h.body, want)
+		}
+		if h.header.Get("X-Negentropy-Event") != eventSessionStop || h.header.Get("Content-Type") != "application/json" {
+			t.Errorf("headers = %v", h.header)
+		}
+	}
+	signed, plain := got[0], got[1]
+	if signed.header.Get("X-Negentropy-Signature") == "" {
+		signed, plain = plain, signed
+	}
+	if plain.header.Get("X-Negentropy-Signature") != "" {
+		t.Error("signed without a secret")
+	}
+	ts := signed.header.Get("X-Negentropy-Timestamp")
+	sig, ok := strings.CutPrefix(signed.header.Get("X-Negentropy-Signature"), "sha256=")
+	if !ok || ts == "" {
+		t.Fatalf("signature %q timestamp %q", signed.header.Get("X-Negentropy-Signature"), ts)
+	}
+	// what a receiver would check
+	if !hmac.Equal([]byte(sig), []byte(signWebhook("s3cret", ts, signed.body))) {
+		t.Error("signature doesn't match the body")
+	}
+	if signWebhook("s3cret", ts, signed.body) == signWebhook("s3cret", ts+"0", signed.body) {
+		t.Error("timestamp isn't signed")
+	}
+	if rows := outbox(t, sqldb); len(rows) != 0 {
+		t.Errorf("outbox after delivery = %+v", rows)
+	}
+}
+
+func TestWebhookRetry(t *testing.T) {
+	tests := []struct {
+		name      string
+		statuses  []int
+		attempts  int64 // of the delivery before the flush
+		permanent bool
+	}{
+		{name: "server error", statuses: []int{http.StatusServiceUnavailable}},
+		{name: "too many requests", statuses: []int{http.StatusTooManyRequests}},
+		{name: "timeout", statuses: []int{http.StatusRequestTimeout}},
+		{name: "bad request", statuses: []int{http.StatusBadRequest}, permanent: true},
+		{name: "gone", statuses: []int{http.StatusGone}, permanent: true},
+		{name: "out of attempts", statuses: []int{http.StatusServiceUnavailable}, attempts: webhookMaxAttempts - 1, permanent: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sqldb, q := openTestDB(t)
+			r := newReceiver(t, tt.statuses...)
+			s := newWebhookSender(q, []webhookConfig{{URL: r.URL}})
+			ctx := context.Background()
+			now := time.Now()
+			payload, _ := json.Marshal(stopEvent())
+			d, err := q.EnqueueWebhook(ctx, db.EnqueueWebhookParams{
+				Url: r.URL, Event: eventSessionStop, Payload: string(payload),
+				NextAttemptAt: formatTimestamp(now), CreatedAt: formatTimestamp(now),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := sqldb.Exec("UPDATE webhook_outbox SET attempts = ? WHERE id = ?", tt.attempts, d.ID); err != nil {
+				t.Fatal(err)
+			}
+
+			s.flush(ctx, now)
+			rows := outbox(t, sqldb)
+			if len(rows) != 1 {
+				t.Fatalf("outbox = %+v", rows)
+			}
+			d = rows[0]
+			if d.Attempts != tt.attempts+1 || !strings.HasPrefix(d.LastError.String, strconv.Itoa(tt.statuses[0])) {
+				t.Errorf("attempts %d last error %q", d.Attempts, d.LastError.String)
+			}
+			if d.FailedAt.Valid != tt.permanent {
+				t.Fatalf("failed at %v, want failed %v", d.FailedAt, tt.permanent)
+			}
+
+			later := now.Add(webhookRetryAfter(tt.attempts) + time.Second)
+			if tt.permanent {
+				s.flush(ctx, later.Add(24*time.Hour))
+				if n := len(r.received()); n != 1 {
+					t.Errorf("a failed delivery was sent again, %d requests", n)
+				}
+				return
+			}
+			next, err := parseTimestamp(d.NextAttemptAt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if wait := next.Sub(now); wait < webhookBackoff-time.Second || wait > webhookBackoff+2*time.Second {
+				t.Errorf("next attempt in %v, want %v", wait, webhookBackoff)
+			}
+			// not due yet
+			s.flush(ctx, now.Add(webhookBackoff/2))
+			if n := len(r.received()); n != 1 {
+				t.Fatalf("retried before the backoff, %d requests", n)
+			}
+			s.flush(ctx, later)
+			if n := len(r.received()); n != 2 {
+				t.Fatalf("%d requests after the backoff, want 2", n)
+			}
+			if rows := outbox(t, sqldb); len(rows) != 0 {
+				t.Errorf("outbox after the retry = %+v", rows)
+			}
+		})
+	}
+}
+
+func TestWebhookRetryAfter(t *testing.T) {
+	for attempts, want := range map[int64]time.Duration{
+		0:  30 * time.Second,
+		1:  time.Minute,
+		2:  2 * time.Minute,
+		6:  32 * time.Minute,
+		7:  time.Hour,
+		29: time.Hour,
+	} {
+		if got := webhookRetryAfter(attempts); got != want {
+			t.Errorf("after %d attempts wait %v, want %v", attempts, got, want)
+		}
+	}
+}
+
+func TestWebhookOutboxSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.sqlite")
+	sqldb := openTestDBAt(t, path, -1)
+	// down while the event happens, fine again later
+	r := newReceiver(t, http.StatusBadGateway)
+
+	s := newWebhookSender(db.New(sqldb), []webhookConfig{{URL: r.URL}})
+	s.handle(stopEvent())
+	s.wait()
+	if rows := outbox(t, sqldb); len(rows) != 1 || rows[0].Attempts != 1 || rows[0].FailedAt.Valid {
+		t.Fatalf("outbox while down = %+v", rows)
+	}
+	sqldb.Close()
+
+	// the next process finds it in the database and sends it
+	reopened, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	s = newWebhookSender(db.New(reopened), []webhookConfig{{URL: r.URL}})
+	s.flush(context.Background(), time.Now().Add(time.Hour))
+	got := r.received()
+	if len(got) != 2 || string(got[0].body) != string(got[1].body) {
+		t.Fatalf("deliveries after the restart = %d, want the same event twice", len(got))
+	}
+	if rows := outbox(t, reopened); len(rows) != 0 {
+		t.Errorf("outbox after the restart = %+v", rows)
+	}
+}
+
+func TestWebhookRemovedFromConfig(t *testing.T) {
+	sqldb, q := openTestDB(t)
+	r := newReceiver(t)
+	ctx := context.Background()
+	now := formatTimestamp(time.Now())
+	// queued before the url was taken out of the config
+	if _, err := q.EnqueueWebhook(ctx, db.EnqueueWebhookParams{Url: r.URL + "/old", Event: eventSessionStop, Payload: "{}", NextAttemptAt: now, CreatedAt: now}); err != nil {
+		t.Fatal(err)
+	}
+	newWebhookSender(q, []webhookConfig{{URL: r.URL + "/new"}}).flush(ctx, time.Now())
+	if n := len(r.received()); n != 0 {
+		t.Errorf("sent %d deliveries to a url that's no longer configured", n)
+	}
+	if rows := outbox(t, sqldb); len(rows) != 0 {
+		t.Errorf("outbox = %+v", rows)
+	}
+}